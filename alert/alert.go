@@ -0,0 +1,286 @@
+// Package alert implements a hook which sends notifications when matching entries
+// occur. Entries are grouped in windows and every group is sent as a digest, e.g.
+//
+//	37 errors in the last 5m
+//	top 3 callers:
+//	  20 db.go:42 connection refused
+//	  10 http.go:88 upstream timeout
+//	   7 cache.go:17 evicted
+package alert
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkideal/log/logger"
+)
+
+// Notifier sends notifications
+type Notifier interface {
+	Notify(n *Notification) error
+}
+
+// NotifierFunc is a function which implements Notifier interface
+type NotifierFunc func(n *Notification) error
+
+// Notify calls f(n)
+func (f NotifierFunc) Notify(n *Notification) error { return f(n) }
+
+// CallerCount represents number of entries of a caller
+type CallerCount struct {
+	Caller  string `json:"caller"`  // file:line
+	Count   int    `json:"count"`   // number of entries
+	Message string `json:"message"` // message of the first entry
+}
+
+// Notification represents a digest of entries in a window
+type Notification struct {
+	Title    string          `json:"title"`    // e.g. 37 errors in the last 5m
+	Count    int             `json:"count"`    // number of entries
+	Window   logger.Duration `json:"window"`   // duration of the window
+	First    time.Time       `json:"first"`    // time of the first entry
+	Last     time.Time       `json:"last"`     // time of the last entry
+	Levels   map[string]int  `json:"levels"`   // number of entries by level
+	Top      []CallerCount   `json:"top"`      // callers which have most entries
+	Hostname string          `json:"hostname"` // hostname of the process
+}
+
+// Text returns the digest message
+func (n *Notification) Text() string {
+	var buf bytes.Buffer
+	buf.WriteString(n.Title)
+	buf.WriteByte('\n')
+	if len(n.Top) > 0 {
+		fmt.Fprintf(&buf, "top %d callers:\n", len(n.Top))
+		width := len(strconv.Itoa(n.Top[0].Count))
+		for _, c := range n.Top {
+			fmt.Fprintf(&buf, "  %*d %s %s\n", width, c.Count, c.Caller, c.Message)
+		}
+	}
+	return buf.String()
+}
+
+// Opts represents options of Alerter
+type Opts struct {
+	Match       func(e logger.Entry) bool // entries notified(default: ERROR and more severe), e.g. provider.Filter.Match
+	Window      time.Duration             // grouping window which starts from the first entry(default: 1m)
+	MinInterval time.Duration             // min interval between notifications, entries are grouped until it elapsed
+	TopN        int                       // number of top callers in the digest(default: 3)
+	Notifiers   []Notifier                // notifiers which all notifications are sent to
+	OnError     func(err error)           // called with errors of notifiers(default: writes to stderr)
+}
+
+func (opts *Opts) setDefaults() {
+	if opts.Match == nil {
+		opts.Match = func(e logger.Entry) bool { return !e.Level().MoreVerboseThan(logger.ERROR) }
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.TopN <= 0 {
+		opts.TopN = 3
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) { fmt.Fprintf(os.Stderr, "log: alert error: %v\n", err) }
+	}
+}
+
+// group holds entries in a window
+type group struct {
+	first, last time.Time
+	count       int
+	verbose     bool // some entries are more verbose than ERROR
+	levels      map[string]int
+	callers     map[string]*CallerCount
+}
+
+func (g *group) add(e logger.Entry) {
+	g.count++
+	g.last = logger.Detailed(e).Time()
+	if e.Level().MoreVerboseThan(logger.ERROR) {
+		g.verbose = true
+	}
+	g.levels[e.Level().String()]++
+	file, line := logger.Detailed(e).Caller()
+	caller := file + ":" + strconv.Itoa(line)
+	if c, ok := g.callers[caller]; ok {
+		c.Count++
+		return
+	}
+	g.callers[caller] = &CallerCount{Caller: caller, Count: 1, Message: string(e.Desc())}
+}
+
+func (g *group) notification(window time.Duration, topN int) *Notification {
+	singular, plural := "error", "errors"
+	if g.verbose {
+		singular, plural = "entry", "entries"
+	}
+	noun := plural
+	if g.count == 1 {
+		noun = singular
+	}
+	n := &Notification{
+		Title:  fmt.Sprintf("%d %s in the last %s", g.count, noun, formatDuration(window)),
+		Count:  g.count,
+		Window: logger.Duration(window),
+		First:  g.first,
+		Last:   g.last,
+		Levels: g.levels,
+	}
+	n.Hostname, _ = os.Hostname()
+	for _, c := range g.callers {
+		n.Top = append(n.Top, *c)
+	}
+	sort.Slice(n.Top, func(i, j int) bool {
+		if n.Top[i].Count != n.Top[j].Count {
+			return n.Top[i].Count > n.Top[j].Count
+		}
+		return n.Top[i].Caller < n.Top[j].Caller
+	})
+	if len(n.Top) > topN {
+		n.Top = n.Top[:topN]
+	}
+	return n
+}
+
+// formatDuration formats d without zero units, e.g. 5m instead of 5m0s
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// Alerter is a logger.Handler which groups matching entries in windows and sends
+// digests to notifiers in its own goroutine, e.g.
+//
+//	a := alert.New(alert.Opts{
+//		Window:    5 * time.Minute,
+//		Notifiers: []alert.Notifier{&alert.Webhook{URL: "http://alertmanager/hook"}},
+//	})
+//	log.AddHook(a, logger.HookOpts{})
+//	defer a.Close()
+type Alerter struct {
+	opts Opts
+
+	mu       sync.Mutex
+	group    *group
+	timer    *time.Timer
+	lastSent time.Time
+	closed   bool
+	sending  sync.WaitGroup
+}
+
+// New creates an Alerter
+func New(opts Opts) *Alerter {
+	opts.setDefaults()
+	return &Alerter{opts: opts}
+}
+
+// Handle implements logger.Handler interface
+func (a *Alerter) Handle(e logger.Entry) error {
+	if !a.opts.Match(e) {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil
+	}
+	if a.group == nil {
+		a.group = &group{
+			first:   logger.Detailed(e).Time(),
+			levels:  make(map[string]int),
+			callers: make(map[string]*CallerCount),
+		}
+		a.timer = time.AfterFunc(a.opts.Window, a.fire)
+	}
+	a.group.add(e)
+	return nil
+}
+
+// fire sends the group if the rate limit allows, otherwise it's delayed
+func (a *Alerter) fire() {
+	a.mu.Lock()
+	if a.closed || a.group == nil {
+		a.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	if wait := a.lastSent.Add(a.opts.MinInterval).Sub(now); wait > 0 {
+		a.timer = time.AfterFunc(wait, a.fire)
+		a.mu.Unlock()
+		return
+	}
+	g := a.group
+	a.group = nil
+	a.lastSent = now
+	a.sending.Add(1)
+	a.mu.Unlock()
+	defer a.sending.Done()
+
+	window := a.opts.Window
+	if elapsed := now.Sub(g.first); elapsed > window+time.Second {
+		// delayed by the rate limit
+		window = elapsed.Round(time.Second)
+	}
+	a.send(g.notification(window, a.opts.TopN))
+}
+
+func (a *Alerter) send(n *Notification) error {
+	var errs []string
+	for _, notifier := range a.opts.Notifiers {
+		if err := notifier.Notify(n); err != nil {
+			a.opts.OnError(err)
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Flush sends the pending group immediately regardless of the window and the rate limit
+func (a *Alerter) Flush() error {
+	a.mu.Lock()
+	g := a.group
+	a.group = nil
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	if g == nil {
+		a.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	a.lastSent = now
+	a.mu.Unlock()
+	window := now.Sub(g.first)
+	if window >= time.Second {
+		window = window.Round(time.Second)
+	} else {
+		window = window.Round(time.Millisecond)
+	}
+	return a.send(g.notification(window, a.opts.TopN))
+}
+
+// Close sends the pending group and stops the alerter after notifications sent
+func (a *Alerter) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
+	err := a.Flush()
+	a.sending.Wait()
+	return err
+}
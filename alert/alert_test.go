@@ -0,0 +1,233 @@
+package alert
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+)
+
+type nopProvider struct{}
+
+func (nopProvider) Write(level logger.Level, headerLength int, data []byte) error { return nil }
+func (nopProvider) Close() error                                                  { return nil }
+
+func newLogger(a *Alerter) logger.Logger {
+	l := logger.NewSync(nopProvider{})
+	l.SetLevel(logger.TRACE)
+	l.Hook(a)
+	return l
+}
+
+func TestAlerterDigest(t *testing.T) {
+	notifications := make(chan *Notification, 1)
+	a := New(Opts{
+		Window:    50 * time.Millisecond,
+		Notifiers: []Notifier{NotifierFunc(func(n *Notification) error { notifications <- n; return nil })},
+	})
+	l := newLogger(a)
+	for i := 0; i < 3; i++ {
+		l.Error(0, "connection refused")
+	}
+	for i := 0; i < 2; i++ {
+		l.Error(0, "timeout")
+	}
+	l.Error(0, "evicted")
+	l.Warn(0, "ignored")
+	l.Error(0, "disk full")
+
+	n := <-notifications
+	assert.Equal(t, 7, n.Count)
+	assert.Equal(t, "7 errors in the last 50ms", n.Title)
+	assert.Equal(t, map[string]int{"ERROR": 7}, n.Levels)
+	assert.Equal(t, 3, len(n.Top))
+	lines := strings.Split(n.Text(), "\n")
+	assert.Equal(t, "top 3 callers:", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "  3 alert_test.go:"), lines[2])
+	assert.True(t, strings.HasSuffix(lines[2], " connection refused"), lines[2])
+	assert.True(t, strings.HasPrefix(lines[3], "  2 alert_test.go:"), lines[3])
+	assert.True(t, strings.HasPrefix(lines[4], "  1 alert_test.go:"), lines[4])
+
+	l.Error(0, "pending")
+	assert.Nil(t, a.Close())
+	n = <-notifications
+	assert.Equal(t, 1, n.Count)
+	l.Error(0, "closed")
+	assert.Nil(t, a.Flush())
+	assert.Equal(t, 0, len(notifications))
+}
+
+func TestAlerterRateLimit(t *testing.T) {
+	sent := make(chan time.Time, 2)
+	a := New(Opts{
+		Match:       func(e logger.Entry) bool { return e.Level() == logger.WARN },
+		Window:      10 * time.Millisecond,
+		MinInterval: 200 * time.Millisecond,
+		Notifiers: []Notifier{NotifierFunc(func(n *Notification) error {
+			assert.Equal(t, "1 entry in the last 10ms", n.Title)
+			sent <- time.Now()
+			return nil
+		})},
+	})
+	defer a.Close()
+	l := newLogger(a)
+	l.Warn(0, "first")
+	first := <-sent
+	l.Error(0, "ignored")
+	l.Warn(0, "second")
+	second := <-sent
+	assert.True(t, second.Sub(first) >= 200*time.Millisecond, "%v", second.Sub(first))
+}
+
+func TestAlerterError(t *testing.T) {
+	var errs []string
+	a := New(Opts{
+		Notifiers: []Notifier{NotifierFunc(func(n *Notification) error { return os.ErrClosed })},
+		OnError:   func(err error) { errs = append(errs, err.Error()) },
+	})
+	newLogger(a).Error(0, "failed")
+	assert.NotNil(t, a.Close())
+	assert.Equal(t, []string{os.ErrClosed.Error()}, errs)
+}
+
+func newNotification() *Notification {
+	g := &group{first: time.Now(), last: time.Now(), levels: map[string]int{}, callers: map[string]*CallerCount{}}
+	g.count = 37
+	g.levels["ERROR"] = 37
+	g.callers["db.go:42"] = &CallerCount{Caller: "db.go:42", Count: 30, Message: "connection refused"}
+	g.callers["http.go:8"] = &CallerCount{Caller: "http.go:8", Count: 7, Message: "timeout"}
+	return g.notification(5*time.Minute, 3)
+}
+
+func TestNotificationText(t *testing.T) {
+	assert.Equal(t, "37 errors in the last 5m\ntop 2 callers:\n  30 db.go:42 connection refused\n   7 http.go:8 timeout\n", newNotification().Text())
+	assert.Equal(t, "2h", formatDuration(2*time.Hour))
+	assert.Equal(t, "1h30m", formatDuration(90*time.Minute))
+	assert.Equal(t, "1.5s", formatDuration(1500*time.Millisecond))
+}
+
+func TestWebhook(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "secret", r.Header.Get("X-Token"))
+		json.NewDecoder(r.Body).Decode(&got)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	w := &Webhook{URL: server.URL + "/hook", Headers: map[string]string{"X-Token": "secret"}}
+	assert.Nil(t, w.Notify(newNotification()))
+	assert.Equal(t, "37 errors in the last 5m", got["title"])
+	assert.Equal(t, "5m0s", got["window"])
+	assert.Equal(t, float64(37), got["count"])
+	assert.Equal(t, 2, len(got["top"].([]interface{})))
+
+	w.URL = server.URL + "/fail"
+	assert.NotNil(t, w.Notify(newNotification()))
+}
+
+// smtpStub is a minimal SMTP server which records received messages
+func smtpStub(t *testing.T) (addr string, messages chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages = make(chan string, 1)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var (
+			r    = bufio.NewReader(conn)
+			data strings.Builder
+			in   = false
+		)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 stub")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if in {
+				if line == ".\r\n" {
+					in = false
+					messages <- data.String()
+					reply("250 ok")
+				} else {
+					data.WriteString(line)
+				}
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+				reply("250 ok")
+			case "DATA":
+				in = true
+				reply("354 go ahead")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func TestSMTP(t *testing.T) {
+	addr, messages := smtpStub(t)
+	s := &SMTP{Addr: addr, From: "log@example.com", To: []string{"ops@example.com", "dev@example.com"}}
+	assert.Nil(t, s.Notify(newNotification()))
+	msg := <-messages
+	assert.Contains(t, msg, "To: ops@example.com, dev@example.com\r\n")
+	assert.Contains(t, msg, "Subject: [log] 37 errors in the last 5m")
+	assert.Contains(t, msg, "\r\n\r\n37 errors in the last 5m\r\ntop 2 callers:\r\n  30 db.go:42 connection refused\r\n")
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh required")
+	}
+	dir, err := ioutil.TempDir("", "alert")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	c := &Command{Name: "sh", Args: []string{"-c", `echo "$LOG_ALERT_COUNT $LOG_ALERT_TITLE" > ` + out + `; cat >> ` + out}}
+	assert.Nil(t, c.Notify(newNotification()))
+	data, err := ioutil.ReadFile(out)
+	assert.Nil(t, err)
+	lines := strings.SplitN(string(data), "\n", 2)
+	assert.Equal(t, "37 37 errors in the last 5m", lines[0])
+	var n Notification
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &n))
+	assert.Equal(t, 37, n.Count)
+
+	c = &Command{Name: "sh", Args: []string{"-c", "echo oops >&2; exit 3"}}
+	err = c.Notify(newNotification())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "oops")
+
+	c = &Command{Name: "sleep", Args: []string{"10"}, Timeout: 50 * time.Millisecond}
+	assert.NotNil(t, c.Notify(newNotification()))
+}
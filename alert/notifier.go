@@ -0,0 +1,131 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook posts notifications in JSON to the URL
+type Webhook struct {
+	URL     string            // url of the webhook
+	Headers map[string]string // extra headers of requests
+	Timeout time.Duration     // timeout of requests(default: 10s)
+	Client  *http.Client      // client of requests(default: a client with Timeout)
+}
+
+// Notify implements Notifier interface
+func (w *Webhook) Notify(n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	client := w.Client
+	if client == nil {
+		timeout := w.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s: %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// SMTP sends notifications by email, the digest is the body and the title is the subject
+type SMTP struct {
+	Addr   string    // address of the server, e.g. smtp.example.com:25
+	Auth   smtp.Auth // authentication, e.g. smtp.PlainAuth, nil if not required
+	From   string    // sender address
+	To     []string  // recipient addresses
+	Prefix string    // prefix of the subject(default: [log])
+}
+
+// Notify implements Notifier interface
+func (s *SMTP) Notify(n *Notification) error {
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "[log]"
+	}
+	subject := prefix + " " + n.Title
+	if n.Hostname != "" {
+		subject += " on " + n.Hostname
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.Last.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.Replace(n.Text(), "\n", "\r\n", -1))
+	return smtp.SendMail(s.Addr, s.Auth, s.From, s.To, msg.Bytes())
+}
+
+// Command runs a command for every notification, the notification in JSON is written
+// to stdin, and title and count are set to environment variables LOG_ALERT_TITLE and
+// LOG_ALERT_COUNT
+type Command struct {
+	Name    string        // name or path of the command
+	Args    []string      // arguments of the command
+	Timeout time.Duration // the command is killed after timeout(default: 30s)
+}
+
+// Notify implements Notifier interface
+func (c *Command) Notify(n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	cmd := exec.Command(c.Name, c.Args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"LOG_ALERT_TITLE="+n.Title,
+		"LOG_ALERT_COUNT="+strconv.Itoa(n.Count),
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		<-done
+		err = fmt.Errorf("timeout after %v", timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("command %s: %v: %s", c.Name, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
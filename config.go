@@ -0,0 +1,544 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mkideal/log/logger"
+	"github.com/mkideal/log/provider"
+)
+
+// Config represents a declarative configuration of global logger, e.g. in YAML:
+//
+//	level: info
+//	header: short
+//	modules:
+//	  db: debug
+//	outputs:
+//	  - type: console
+//	    max_level: warn
+//	  - type: file
+//	    format: json
+//	    options:
+//	      dir: /var/log/app
+//	      filename: app.log
+type Config struct {
+	Level   string            // log level(default: info)
+	Header  string            // header format: default/short/none
+	Sync    bool              // creates a sync logger if true
+	Isolate bool              // writes every output in its own goroutine with its own queue, see provider.IsolatedMix
+	Modules map[string]string // levels of modules
+	Outputs []OutputConfig    // outputs, at least one output required
+}
+
+// OutputConfig represents configuration of an output
+type OutputConfig struct {
+	Type     string                 // registered provider type, e.g. console, file
+	Format   string                 // text or json(default: text)
+	MinLevel string                 // the least severe level written to the output(default: trace)
+	MaxLevel string                 // the most severe level written to the output(default: panic)
+	Filter   string                 // filter expression of entries written to the output, see provider.Filter
+	Options  map[string]interface{} // options of the provider
+}
+
+// ConfigError represents an error of configuration, Key is the path of the invalid key,
+// e.g. outputs[0].type
+type ConfigError struct {
+	Key string
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Key == "" {
+		return "log config: " + e.Err.Error()
+	}
+	return "log config: " + e.Key + ": " + e.Err.Error()
+}
+
+func configError(key string, format string, args ...interface{}) error {
+	return &ConfigError{Key: key, Err: fmt.Errorf(format, args...)}
+}
+
+// ParseConfig parses configuration from data in format json or yaml
+func ParseConfig(data []byte, format string) (*Config, error) {
+	tree, err := parseConfigTree(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return decodeConfig(tree)
+}
+
+// LoadConfig loads configuration from file filename and environment variables
+// prefixed by envPrefix, both of them are optional. The format of file is determined
+// by its extension(.json, .yaml or .yml).
+//
+// Environment variables override values of file, keys are separated by "__" and
+// indices of outputs are numbers, e.g. with envPrefix "LOG":
+//
+//	LOG_LEVEL=debug
+//	LOG_MODULES__DB=trace
+//	LOG_OUTPUTS__0__TYPE=file
+//	LOG_OUTPUTS__0__OPTIONS__DIR=/var/log/app
+func LoadConfig(filename, envPrefix string) (*Config, error) {
+	var tree interface{} = map[string]interface{}{}
+	if filename != "" {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+		format := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+		if tree, err = parseConfigTree(data, format); err != nil {
+			return nil, err
+		}
+	}
+	if envPrefix != "" {
+		tree = mergeConfigTree(tree, envConfigTree(envPrefix, os.Environ()))
+	}
+	return decodeConfig(tree)
+}
+
+func parseConfigTree(data []byte, format string) (interface{}, error) {
+	var tree interface{}
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&tree); err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, &ConfigError{Err: err}
+		}
+	default:
+		return nil, &ConfigError{Err: errors.New("unsupported config format: " + strconv.Quote(format))}
+	}
+	if tree == nil {
+		tree = map[string]interface{}{}
+	}
+	return tree, nil
+}
+
+// envConfigTree builds a tree from environment variables prefixed by prefix
+func envConfigTree(prefix string, environ []string) interface{} {
+	prefix = strings.ToUpper(prefix) + "_"
+	tree := map[string]interface{}{}
+	for _, kv := range environ {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 || !strings.HasPrefix(strings.ToUpper(kv[:i]), prefix) {
+			continue
+		}
+		keys := strings.Split(strings.ToLower(kv[len(prefix):i]), "__")
+		node := tree
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := node[key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[key] = child
+			}
+			node = child
+		}
+		node[keys[len(keys)-1]] = kv[i+1:]
+	}
+	return tree
+}
+
+// mergeConfigTree merges src into dst, maps with numeric keys in src are merged into slices of dst
+func mergeConfigTree(dst, src interface{}) interface{} {
+	sm, ok := src.(map[string]interface{})
+	if !ok {
+		return src
+	}
+	switch d := dst.(type) {
+	case map[string]interface{}:
+		for key, value := range sm {
+			d[key] = mergeConfigTree(d[key], value)
+		}
+		return d
+	case []interface{}:
+		for key, value := range sm {
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 {
+				continue
+			}
+			for len(d) <= i {
+				d = append(d, nil)
+			}
+			d[i] = mergeConfigTree(d[i], value)
+		}
+		return d
+	}
+	if isArrayNode(sm) {
+		return mergeConfigTree([]interface{}{}, sm)
+	}
+	return mergeConfigTree(map[string]interface{}{}, sm)
+}
+
+func isArrayNode(m map[string]interface{}) bool {
+	if len(m) == 0 {
+		return false
+	}
+	for key := range m {
+		if _, err := strconv.Atoi(key); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func decodeConfig(tree interface{}) (*Config, error) {
+	root, ok := tree.(map[string]interface{})
+	if !ok {
+		return nil, configError("", "object required")
+	}
+	cfg := new(Config)
+	for _, key := range sortedKeys(root) {
+		var (
+			value = root[key]
+			err   error
+		)
+		switch key {
+		case "level":
+			cfg.Level, err = decodeString(key, value)
+		case "header":
+			cfg.Header, err = decodeString(key, value)
+		case "sync":
+			cfg.Sync, err = decodeBool(key, value)
+		case "isolate":
+			cfg.Isolate, err = decodeBool(key, value)
+		case "modules":
+			cfg.Modules, err = decodeModules(key, value)
+		case "outputs":
+			cfg.Outputs, err = decodeOutputs(key, value)
+		default:
+			err = configError(key, "unknown key")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func decodeString(key string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case int, float64:
+		return fmt.Sprint(v), nil
+	}
+	return "", configError(key, "string required")
+}
+
+func decodeBool(key string, value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+	}
+	return false, configError(key, "bool required")
+}
+
+func decodeModules(key string, value interface{}) (map[string]string, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, configError(key, "object required")
+	}
+	modules := make(map[string]string, len(m))
+	for _, name := range sortedKeys(m) {
+		level, err := decodeString(joinKey(key, name), m[name])
+		if err != nil {
+			return nil, err
+		}
+		modules[name] = level
+	}
+	return modules, nil
+}
+
+func decodeOutputs(key string, value interface{}) ([]OutputConfig, error) {
+	if m, ok := value.(map[string]interface{}); ok && isArrayNode(m) {
+		value = mergeConfigTree([]interface{}{}, m)
+	}
+	s, ok := value.([]interface{})
+	if !ok {
+		return nil, configError(key, "array required")
+	}
+	outputs := make([]OutputConfig, len(s))
+	for i, v := range s {
+		if err := decodeOutput(fmt.Sprintf("%s[%d]", key, i), v, &outputs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return outputs, nil
+}
+
+func decodeOutput(key string, value interface{}, output *OutputConfig) error {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return configError(key, "object required")
+	}
+	for _, name := range sortedKeys(m) {
+		var (
+			subkey = joinKey(key, name)
+			err    error
+		)
+		switch name {
+		case "type":
+			output.Type, err = decodeString(subkey, m[name])
+		case "format":
+			output.Format, err = decodeString(subkey, m[name])
+		case "min_level":
+			output.MinLevel, err = decodeString(subkey, m[name])
+		case "max_level":
+			output.MaxLevel, err = decodeString(subkey, m[name])
+		case "filter":
+			output.Filter, err = decodeString(subkey, m[name])
+		case "options":
+			options, ok := m[name].(map[string]interface{})
+			if !ok {
+				return configError(subkey, "object required")
+			}
+			output.Options = decodeOptions(options)
+		default:
+			err = configError(subkey, "unknown key")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeOptions converts string values which come from environment variables to numbers or bools
+func decodeOptions(options map[string]interface{}) map[string]interface{} {
+	for key, value := range options {
+		switch v := value.(type) {
+		case string:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				options[key] = n
+			} else if f, err := strconv.ParseFloat(v, 64); err == nil {
+				options[key] = f
+			} else if b, err := strconv.ParseBool(v); err == nil {
+				options[key] = b
+			}
+		case map[string]interface{}:
+			options[key] = decodeOptions(v)
+		}
+	}
+	return options
+}
+
+func parseConfigLevel(key, s string, dft logger.Level) (logger.Level, error) {
+	if s == "" {
+		return dft, nil
+	}
+	level, ok := ParseLevel(s)
+	if !ok {
+		return dft, configError(key, "unrecognized log level %q", s)
+	}
+	return level, nil
+}
+
+// Validate validates the configuration, errors are of type *ConfigError
+func (cfg *Config) Validate() error {
+	if _, err := parseConfigLevel("level", cfg.Level, LvINFO); err != nil {
+		return err
+	}
+	if _, err := logger.ParseHeaderFormat(cfg.Header); err != nil {
+		return configError("header", "unrecognized header format %q", cfg.Header)
+	}
+	for name, level := range cfg.Modules {
+		if _, err := parseConfigLevel(joinKey("modules", name), level, LvINFO); err != nil {
+			return err
+		}
+	}
+	if len(cfg.Outputs) == 0 {
+		return configError("outputs", "at least one output required")
+	}
+	for i := range cfg.Outputs {
+		if err := cfg.Outputs[i].validate(fmt.Sprintf("outputs[%d]", i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (output *OutputConfig) validate(key string) error {
+	if output.Type == "" {
+		return configError(key+".type", "type required")
+	}
+	if logger.Lookup(output.Type) == nil {
+		return configError(key+".type", "unregistered provider type %q", output.Type)
+	}
+	switch output.Format {
+	case "", "text", "json":
+	default:
+		return configError(key+".format", "unrecognized format %q", output.Format)
+	}
+	min, err := parseConfigLevel(key+".min_level", output.MinLevel, LvTRACE)
+	if err != nil {
+		return err
+	}
+	max, err := parseConfigLevel(key+".max_level", output.MaxLevel, LvPANIC)
+	if err != nil {
+		return err
+	}
+	if max.MoreVerboseThan(min) {
+		return configError(key+".max_level", "max level %s is more verbose than min level %s", max, min)
+	}
+	if output.Filter != "" {
+		if _, err := provider.ParseFilter(output.Filter); err != nil {
+			return &ConfigError{Key: key + ".filter", Err: err}
+		}
+	}
+	if _, err := json.Marshal(output.Options); err != nil {
+		return configError(key+".options", "%v", err)
+	}
+	return nil
+}
+
+// NewProvider creates the provider of the output
+func (output *OutputConfig) NewProvider() (logger.Provider, error) {
+	return output.newProvider("output", output.Type)
+}
+
+// newProvider creates the provider of the output, metrics of entries passed filters
+// are labeled by name
+func (output *OutputConfig) newProvider(key, name string) (logger.Provider, error) {
+	if err := output.validate(key); err != nil {
+		return nil, err
+	}
+	opts := ""
+	if len(output.Options) > 0 {
+		b, _ := json.Marshal(output.Options)
+		opts = string(b)
+	}
+	p := logger.Lookup(output.Type)(opts)
+	if hr, ok := p.(provider.HealthReporter); ok {
+		if h := hr.Health(); h.State == provider.Failed {
+			p.Close()
+			return nil, &ConfigError{Key: key + ".options", Err: h.LastError}
+		}
+	}
+	if output.Format == "json" {
+		p = provider.NewJSON(p)
+	}
+	p = provider.Instrument(p, name)
+	if output.MinLevel != "" || output.MaxLevel != "" {
+		min, _ := parseConfigLevel("", output.MinLevel, LvTRACE)
+		max, _ := parseConfigLevel("", output.MaxLevel, LvPANIC)
+		p = provider.NewLevelFilter(p, provider.LevelRange(min, max))
+	}
+	if output.Filter != "" {
+		p = provider.NewFilter(p, provider.MustParseFilter(output.Filter))
+	}
+	return p, nil
+}
+
+// NewLogger creates a logger by the configuration, the logger is not running
+func (cfg *Config) NewLogger() (logger.Logger, error) {
+	p, err := cfg.newProvider()
+	if err != nil {
+		return nil, err
+	}
+	var l logger.Logger
+	if cfg.Sync {
+		l = logger.NewSync(p)
+	} else {
+		l = logger.New(p)
+	}
+	cfg.configure(l)
+	return l, nil
+}
+
+// newProvider creates providers of all outputs
+func (cfg *Config) newProvider() (logger.Provider, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	types := make(map[string]int)
+	for _, output := range cfg.Outputs {
+		types[output.Type]++
+	}
+	providers := make([]logger.Provider, 0, len(cfg.Outputs))
+	for i := range cfg.Outputs {
+		// metrics of outputs are labeled by type, and by index if the type is repeated
+		name := cfg.Outputs[i].Type
+		if types[name] > 1 {
+			name = fmt.Sprintf("%s#%d", name, i)
+		}
+		p, err := cfg.Outputs[i].newProvider(fmt.Sprintf("outputs[%d]", i), name)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	if cfg.Isolate {
+		return provider.NewIsolatedMixProvider(0, providers[0], providers[1:]...), nil
+	}
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+	return provider.NewMixProvider(providers[0], providers[1:]...), nil
+}
+
+// configure applies level, header format and module levels to l
+func (cfg *Config) configure(l logger.Logger) {
+	level, _ := parseConfigLevel("", cfg.Level, LvINFO)
+	l.SetLevel(level)
+	header, _ := logger.ParseHeaderFormat(cfg.Header)
+	if hf, ok := l.(logger.HeaderFormatter); ok {
+		hf.SetHeaderFormat(header)
+	}
+	if ml, ok := l.(logger.ModuleLeveler); ok {
+		ml.ResetModuleLevels()
+		for name, s := range cfg.Modules {
+			level, _ := parseConfigLevel("", s, LvINFO)
+			ml.SetModuleLevel(name, level)
+		}
+	}
+}
+
+// InitWithConfig inits global logger by the configuration
+func InitWithConfig(cfg *Config) error {
+	l, err := cfg.NewLogger()
+	if err != nil {
+		glogger().Error(1, "init log error: %v", err)
+		return err
+	}
+	return InitWithLogger(l)
+}
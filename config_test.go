@@ -0,0 +1,158 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+	"github.com/mkideal/log/provider"
+)
+
+var configTestBuffer = new(bytes.Buffer)
+
+func init() {
+	logger.Register("config_test", func(opts string) logger.Provider {
+		return provider.NewConsoleWithWriter("", configTestBuffer, configTestBuffer)
+	})
+}
+
+func TestParseConfig(t *testing.T) {
+	yamlConfig := `
+level: debug
+header: short
+modules:
+  db: trace
+outputs:
+  - type: console
+    max_level: warn
+    options:
+      tostderrlevel: 1
+  - type: config_test
+    format: json
+    min_level: error
+`
+	cfg, err := ParseConfig([]byte(yamlConfig), "yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "debug", cfg.Level)
+	assert.Equal(t, "short", cfg.Header)
+	assert.Equal(t, map[string]string{"db": "trace"}, cfg.Modules)
+	assert.Equal(t, 2, len(cfg.Outputs))
+	assert.Equal(t, "warn", cfg.Outputs[0].MaxLevel)
+	assert.Equal(t, 1, cfg.Outputs[0].Options["tostderrlevel"])
+	assert.Equal(t, "json", cfg.Outputs[1].Format)
+
+	jsonConfig := `{"level":"info","sync":true,"outputs":[{"type":"config_test","options":{"x":1}}]}`
+	cfg, err = ParseConfig([]byte(jsonConfig), "json")
+	assert.Nil(t, err)
+	assert.True(t, cfg.Sync)
+	assert.Equal(t, json.Number("1"), cfg.Outputs[0].Options["x"])
+
+	cfg, err = ParseConfig([]byte(`{"isolate":true,"outputs":[{"type":"config_test","filter":"level >= warn"}]}`), "json")
+	assert.Nil(t, err)
+	assert.True(t, cfg.Isolate)
+	assert.Equal(t, "level >= warn", cfg.Outputs[0].Filter)
+	p, err := cfg.newProvider()
+	assert.Nil(t, err)
+	assert.IsType(t, &provider.IsolatedMix{}, p)
+	assert.Nil(t, p.Close())
+
+	for _, tt := range []struct {
+		config string
+		key    string
+	}{
+		{`{"levle":"info"}`, "levle"},
+		{`{"level":"verbose","outputs":[{"type":"console"}]}`, "level"},
+		{`{"level":"info"}`, "outputs"},
+		{`{"outputs":[{"type":"console"},{"type":"unknown"}]}`, "outputs[1].type"},
+		{`{"outputs":[{"type":"console","fromat":"json"}]}`, "outputs[0].fromat"},
+		{`{"outputs":[{"type":"console","min_level":"x"}]}`, "outputs[0].min_level"},
+		{`{"outputs":[{"type":"console","min_level":"error","max_level":"debug"}]}`, "outputs[0].max_level"},
+		{`{"outputs":[{"type":"console","filter":"level >"}]}`, "outputs[0].filter"},
+		{`{"modules":{"db":"x"},"outputs":[{"type":"console"}]}`, "modules.db"},
+		{`{"sync":1,"outputs":[{"type":"console"}]}`, "sync"},
+	} {
+		_, err := ParseConfig([]byte(tt.config), "json")
+		if assert.Error(t, err, tt.config) {
+			cerr, ok := err.(*ConfigError)
+			if assert.True(t, ok, tt.config) {
+				assert.Equal(t, tt.key, cerr.Key, tt.config)
+			}
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_config_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "log.json")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(`{"level":"info","outputs":[{"type":"console"}]}`), 0644))
+
+	os.Setenv("LOGCFGTEST_LEVEL", "trace")
+	os.Setenv("LOGCFGTEST_MODULES__DB", "error")
+	os.Setenv("LOGCFGTEST_OUTPUTS__0__OPTIONS__TOSTDERRLEVEL", "2")
+	os.Setenv("LOGCFGTEST_OUTPUTS__1__TYPE", "config_test")
+	defer func() {
+		for _, key := range []string{"LEVEL", "MODULES__DB", "OUTPUTS__0__OPTIONS__TOSTDERRLEVEL", "OUTPUTS__1__TYPE"} {
+			os.Unsetenv("LOGCFGTEST_" + key)
+		}
+	}()
+	cfg, err := LoadConfig(filename, "LOGCFGTEST")
+	assert.Nil(t, err)
+	assert.Equal(t, "trace", cfg.Level)
+	assert.Equal(t, map[string]string{"db": "error"}, cfg.Modules)
+	assert.Equal(t, 2, len(cfg.Outputs))
+	assert.Equal(t, "console", cfg.Outputs[0].Type)
+	assert.Equal(t, int64(2), cfg.Outputs[0].Options["tostderrlevel"])
+	assert.Equal(t, "config_test", cfg.Outputs[1].Type)
+
+	_, err = LoadConfig(filepath.Join(dir, "log.toml"), "")
+	assert.Error(t, err)
+}
+
+func TestInitWithConfig(t *testing.T) {
+	defer InitWithLogger(logger.NewStdLogger())
+	cfg := &Config{
+		Level:   "info",
+		Header:  "none",
+		Sync:    true,
+		Modules: map[string]string{"db": "debug"},
+		Outputs: []OutputConfig{
+			{Type: "config_test", MaxLevel: "warn"},
+			{Type: "config_test", Format: "json", MinLevel: "error"},
+		},
+	}
+	assert.Nil(t, InitWithConfig(cfg))
+	configTestBuffer.Reset()
+
+	Debug("hidden")
+	Info("hello")
+	Module("db/sql").Debug("query")
+	Module("cache").Debug("hidden")
+	With(M{"k": "v"}).Error("failed")
+	lines := bytes.SplitN(configTestBuffer.Bytes(), []byte("\n"), 3)
+	assert.Equal(t, "hello", string(lines[0]))
+	assert.Equal(t, "[db/sql] query", string(lines[1]))
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(lines[2], &entry))
+	assert.NotEmpty(t, entry["time"])
+	delete(entry, "time")
+	assert.Equal(t, map[string]interface{}{"level": "ERROR", "msg": "failed", "data": map[string]interface{}{"k": "v"}}, entry)
+}
+
+func TestConfigProviderError(t *testing.T) {
+	cfg := &Config{Outputs: []OutputConfig{
+		{Type: "console"},
+		{Type: "file", Options: map[string]interface{}{"rotate": "1w"}},
+	}}
+	_, err := cfg.NewLogger()
+	if assert.Error(t, err) {
+		assert.Equal(t, "outputs[1].options", err.(*ConfigError).Key)
+	}
+}
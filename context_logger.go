@@ -56,6 +56,7 @@ type ContextLogger interface {
 	Warn(format string, args ...interface{}) ContextLogger
 	Error(format string, args ...interface{}) ContextLogger
 	Fatal(format string, args ...interface{}) ContextLogger
+	Panic(format string, args ...interface{}) ContextLogger
 }
 
 // contextLogger implements ContextLogger
@@ -64,6 +65,8 @@ type contextLogger struct {
 	data      interface{}
 	formatter Formatter
 	b         []byte
+	value     interface{} // redacted data, valid if b != nil
+	module    string
 }
 
 var bytesTrue = []byte("true")
@@ -111,16 +114,23 @@ func (l *contextLogger) bytes() []byte {
 	if l.b != nil {
 		return l.b
 	}
+	if l.data == nil && l.module != "" {
+		// created by Module, no context data
+		l.b = []byte{}
+		return l.b
+	}
+	l.value = redactValue(l.data)
 	if l.formatter != nil {
-		l.b = l.formatter.Format(l.data)
+		l.b = l.formatter.Format(l.value)
 		return l.b
 	}
-	l.b = toBytes(l.data)
+	l.b = toBytes(l.value)
 	return l.b
 }
 
 func (l *contextLogger) With(values ...interface{}) ContextLogger {
 	l.b = nil
+	values = errorValues(values, 1)
 	if l.data == nil {
 		if len(values) == 0 {
 			l.data = values[0]
@@ -176,8 +186,12 @@ func (l *contextLogger) SetFormatter(f Formatter) ContextLogger {
 }
 
 func (l *contextLogger) formatMessage(format string, args ...interface{}) string {
-	buf := bytes.NewBuffer(l.bytes())
-	if buf.Len() > 0 && len(format) > 0 {
+	buf := new(bytes.Buffer)
+	if l.module != "" {
+		buf.WriteString("[" + l.module + "] ")
+	}
+	buf.Write(l.bytes())
+	if len(l.bytes()) > 0 && len(format) > 0 {
 		buf.WriteString(" | ")
 	}
 	if len(args) == 0 {
@@ -188,68 +202,89 @@ func (l *contextLogger) formatMessage(format string, args ...interface{}) string
 	return buf.String()
 }
 
-func (l *contextLogger) Trace(format string, args ...interface{}) ContextLogger {
-	if l.isTrue && glogger.GetLevel() >= LvTRACE {
-		if wl, ok := glogger.(logger.With); ok {
-			wl.LogWith(LvTRACE, 1, l.bytes(), format, args...)
-		} else {
-			glogger.Trace(1, l.formatMessage(format, args...))
+func (l *contextLogger) output(level logger.Level, format string, args ...interface{}) {
+	if wl, ok := glogger().(logger.ContextWith); ok {
+		data := l.bytes()
+		wl.LogContext(level, 2, logger.Context{Module: l.module, Value: l.value, Data: data}, format, args...)
+		return
+	}
+	if wl, ok := glogger().(logger.With); ok && l.module == "" {
+		wl.LogWith(level, 2, l.bytes(), format, args...)
+		return
+	}
+	msg := l.formatMessage(format, args...)
+	switch level {
+	case LvTRACE:
+		glogger().Trace(2, msg)
+	case LvDEBUG:
+		glogger().Debug(2, msg)
+	case LvINFO:
+		glogger().Info(2, msg)
+	case LvWARN:
+		glogger().Warn(2, msg)
+	case LvERROR:
+		glogger().Error(2, msg)
+	case LvFATAL:
+		glogger().Fatal(2, msg)
+	case LvPANIC:
+		panicf(2, msg)
+	}
+}
+
+func (l *contextLogger) getLevel() logger.Level {
+	if l.module != "" {
+		if ml, ok := glogger().(logger.ModuleLeveler); ok {
+			return ml.GetModuleLevel(l.module)
 		}
 	}
+	return glogger().GetLevel()
+}
+
+func (l *contextLogger) Trace(format string, args ...interface{}) ContextLogger {
+	if l.isTrue && l.getLevel() >= LvTRACE {
+		l.output(LvTRACE, format, args...)
+	}
 	return l
 }
 
 func (l *contextLogger) Debug(format string, args ...interface{}) ContextLogger {
-	if l.isTrue && glogger.GetLevel() >= LvDEBUG {
-		if wl, ok := glogger.(logger.With); ok {
-			wl.LogWith(LvDEBUG, 1, l.bytes(), format, args...)
-		} else {
-			glogger.Debug(1, l.formatMessage(format, args...))
-		}
+	if l.isTrue && l.getLevel() >= LvDEBUG {
+		l.output(LvDEBUG, format, args...)
 	}
 	return l
 }
 
 func (l *contextLogger) Info(format string, args ...interface{}) ContextLogger {
-	if l.isTrue && glogger.GetLevel() >= LvINFO {
-		if wl, ok := glogger.(logger.With); ok {
-			wl.LogWith(LvINFO, 1, l.bytes(), format, args...)
-		} else {
-			glogger.Info(1, l.formatMessage(format, args...))
-		}
+	if l.isTrue && l.getLevel() >= LvINFO {
+		l.output(LvINFO, format, args...)
 	}
 	return l
 }
 
 func (l *contextLogger) Warn(format string, args ...interface{}) ContextLogger {
-	if l.isTrue && glogger.GetLevel() >= LvWARN {
-		if wl, ok := glogger.(logger.With); ok {
-			wl.LogWith(LvWARN, 1, l.bytes(), format, args...)
-		} else {
-			glogger.Warn(1, l.formatMessage(format, args...))
-		}
+	if l.isTrue && l.getLevel() >= LvWARN {
+		l.output(LvWARN, format, args...)
 	}
 	return l
 }
 
 func (l *contextLogger) Error(format string, args ...interface{}) ContextLogger {
-	if l.isTrue && glogger.GetLevel() >= LvERROR {
-		if wl, ok := glogger.(logger.With); ok {
-			wl.LogWith(LvERROR, 1, l.bytes(), format, args...)
-		} else {
-			glogger.Error(1, l.formatMessage(format, args...))
-		}
+	if l.isTrue && l.getLevel() >= LvERROR {
+		l.output(LvERROR, format, args...)
 	}
 	return l
 }
 
 func (l *contextLogger) Fatal(format string, args ...interface{}) ContextLogger {
 	if l.isTrue {
-		if wl, ok := glogger.(logger.With); ok {
-			wl.LogWith(LvFATAL, 1, l.bytes(), format, args...)
-		} else {
-			glogger.Fatal(1, l.formatMessage(format, args...))
-		}
+		l.output(LvFATAL, format, args...)
+	}
+	return l
+}
+
+func (l *contextLogger) Panic(format string, args ...interface{}) ContextLogger {
+	if l.isTrue {
+		l.output(LvPANIC, format, args...)
 	}
 	return l
 }
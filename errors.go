@@ -0,0 +1,281 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mkideal/log/logger"
+)
+
+const maxErrorStackDepth = 32
+
+// stack depth of errors rendered in text mode
+var errorStackDepth int32 = 5
+
+// SetErrorStackDepth sets max number of stack frames of errors rendered in text mode,
+// 0 means no stack. JSON output always contains the whole captured stack.
+func SetErrorStackDepth(depth int) {
+	atomic.StoreInt32(&errorStackDepth, int32(depth))
+}
+
+// ErrField is a context value which renders an error with its cause chain and stack trace
+type ErrField struct {
+	err   error
+	stack []uintptr
+}
+
+// Err creates an ErrField for logging err, e.g.
+//
+//	log.With(log.Err(err)).Error("read config failed")
+//
+// The stack trace of err is used if err or its causes have a StackTrace() method
+// (e.g. errors created by github.com/pkg/errors), otherwise the stack of caller is captured.
+func Err(err error) ErrField {
+	return newErrField(err, 3)
+}
+
+func newErrField(err error, skip int) ErrField {
+	f := ErrField{err: err}
+	if err == nil {
+		return f
+	}
+	if f.stack = stackOfError(err); f.stack == nil {
+		pcs := make([]uintptr, maxErrorStackDepth)
+		n := runtime.Callers(skip, pcs)
+		f.stack = pcs[:n]
+	}
+	return f
+}
+
+// stackOfError returns stack trace of the first error which has a StackTrace() method in the chain
+func stackOfError(err error) []uintptr {
+	for _, e := range errorChain(err) {
+		if pcs := callStackTrace(e); pcs != nil {
+			return pcs
+		}
+	}
+	return nil
+}
+
+// callStackTrace calls method StackTrace of err which returns a slice of
+// program counters like github.com/pkg/errors.StackTrace
+func callStackTrace(err error) []uintptr {
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil
+	}
+	t := m.Type().Out(0)
+	if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	v := m.Call(nil)[0]
+	if v.Len() == 0 {
+		return nil
+	}
+	pcs := make([]uintptr, v.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(v.Index(i).Uint())
+	}
+	return pcs
+}
+
+// errorChain returns err and all its causes in depth-first order,
+// both errors.Unwrap and errors.Join are supported
+func errorChain(err error) []error {
+	var chain []error
+	var walk func(error)
+	walk = func(err error) {
+		for err != nil {
+			chain = append(chain, err)
+			if len(chain) >= 64 {
+				return
+			}
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				for _, e := range joined.Unwrap() {
+					walk(e)
+				}
+				return
+			}
+			err = errors.Unwrap(err)
+		}
+	}
+	walk(err)
+	return chain
+}
+
+func errorType(err error) string {
+	return reflect.TypeOf(err).String()
+}
+
+type errFrame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+func (f ErrField) frames(depth int) []errFrame {
+	if depth <= 0 {
+		return nil
+	}
+	var frames []errFrame
+	for _, frame := range logger.CallersFrames(f.stack, depth, logger.SkipRuntimeFrames) {
+		frames = append(frames, errFrame{
+			Func: shortFuncName(frame.Function),
+			File: filepath.Base(frame.File),
+			Line: frame.Line,
+		})
+	}
+	return frames
+}
+
+func shortFuncName(name string) string {
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// String renders the error in text mode:
+//
+//	message (type=T, causes=[cause1(T1) cause2(T2)], stack=[pkg.f(file.go:10) ...])
+func (f ErrField) String() string {
+	if f.err == nil {
+		return "<nil>"
+	}
+	var (
+		buf   bytes.Buffer
+		chain = errorChain(f.err)
+	)
+	buf.WriteString(f.err.Error())
+	buf.WriteString(" (type=")
+	buf.WriteString(errorType(f.err))
+	if len(chain) > 1 {
+		buf.WriteString(", causes=[")
+		for i, e := range chain[1:] {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(strconv.Quote(e.Error()))
+			buf.WriteByte('(')
+			buf.WriteString(errorType(e))
+			buf.WriteByte(')')
+		}
+		buf.WriteByte(']')
+	}
+	if frames := f.frames(int(atomic.LoadInt32(&errorStackDepth))); len(frames) > 0 {
+		buf.WriteString(", stack=[")
+		for i, frame := range frames {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			fmt.Fprintf(&buf, "%s(%s:%d)", frame.Func, frame.File, frame.Line)
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteByte(')')
+	return buf.String()
+}
+
+// Format implements fmt.Formatter
+func (f ErrField) Format(s fmt.State, verb rune) { io.WriteString(s, f.String()) }
+
+type jsonError struct {
+	Error  string      `json:"error"`
+	Type   string      `json:"type"`
+	Causes []jsonError `json:"causes,omitempty"`
+	Stack  []errFrame  `json:"stack,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (f ErrField) MarshalJSON() ([]byte, error) {
+	if f.err == nil {
+		return []byte("null"), nil
+	}
+	chain := errorChain(f.err)
+	v := jsonError{
+		Error: f.err.Error(),
+		Type:  errorType(f.err),
+		Stack: f.frames(maxErrorStackDepth),
+	}
+	for _, e := range chain[1:] {
+		v.Causes = append(v.Causes, jsonError{Error: e.Error(), Type: errorType(e)})
+	}
+	return json.Marshal(v)
+}
+
+// errorValue wraps error value v by ErrField, error values of v are wrapped if v
+// is a map, e.g. M{"err": err}. Errors nested deeper aren't wrapped. skip is the
+// number of frames to skip while capturing stack, 0 identifying the caller of errorValue
+func errorValue(v interface{}, skip int) interface{} {
+	switch x := v.(type) {
+	case error:
+		return newErrField(x, skip+3)
+	case M:
+		if hasErrorValue(x) {
+			return M(errorFields(x, skip+1))
+		}
+	case map[string]interface{}:
+		if hasErrorValue(x) {
+			return errorFields(x, skip+1)
+		}
+	}
+	return v
+}
+
+// errorFields returns a copy of m whose error values are wrapped by ErrField
+func errorFields(m map[string]interface{}, skip int) map[string]interface{} {
+	copied := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if err, ok := v.(error); ok {
+			v = newErrField(err, skip+3)
+		}
+		copied[k] = v
+	}
+	return copied
+}
+
+// hasErrorValue reports whether errorValue wraps v
+func hasErrorValue(v interface{}) bool {
+	var m map[string]interface{}
+	switch x := v.(type) {
+	case error:
+		return true
+	case M:
+		m = x
+	case map[string]interface{}:
+		m = x
+	}
+	for _, v := range m {
+		if _, ok := v.(error); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// errorValues is similar to errorValue, but wraps all values in values
+func errorValues(values []interface{}, skip int) []interface{} {
+	var copied []interface{}
+	for i, v := range values {
+		if !hasErrorValue(v) {
+			continue
+		}
+		if copied == nil {
+			copied = append([]interface{}(nil), values...)
+		}
+		copied[i] = errorValue(v, skip+1)
+	}
+	if copied == nil {
+		return values
+	}
+	return copied
+}
@@ -0,0 +1,112 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type frame uintptr
+
+type stackTrace []frame
+
+type stackError struct {
+	msg   string
+	stack stackTrace
+}
+
+func newStackError(msg string) error {
+	pcs := make([]uintptr, 8)
+	n := runtime.Callers(1, pcs)
+	err := &stackError{msg: msg}
+	for _, pc := range pcs[:n] {
+		err.stack = append(err.stack, frame(pc))
+	}
+	return err
+}
+
+func (e *stackError) Error() string          { return e.msg }
+func (e *stackError) StackTrace() stackTrace { return e.stack }
+
+func TestErr(t *testing.T) {
+	SetErrorStackDepth(0)
+	defer SetErrorStackDepth(5)
+
+	err := fmt.Errorf("read config: %w", errors.New("not found"))
+	assert.Equal(t, `read config: not found (type=*fmt.wrapError, causes=["not found"(*errors.errorString)])`, Err(err).String())
+
+	joined := fmt.Errorf("wrap: %w", errors.Join(errors.New("a"), errors.New("b")))
+	assert.Equal(t, `wrap: a
+b (type=*fmt.wrapError, causes=["a\nb"(*errors.joinError) "a"(*errors.errorString) "b"(*errors.errorString)])`, Err(joined).String())
+
+	assert.Equal(t, "<nil>", Err(nil).String())
+}
+
+func TestErrStack(t *testing.T) {
+	SetErrorStackDepth(1)
+	defer SetErrorStackDepth(5)
+
+	s := Err(errors.New("e")).String()
+	assert.True(t, strings.HasPrefix(s, "e (type=*errors.errorString, stack=[log.TestErrStack(errors_test.go:"), s)
+
+	// stack trace captured when error created
+	err := newStackError("with stack")
+	s = fmt.Sprint(Err(fmt.Errorf("wrapped: %w", err)))
+	assert.True(t, strings.Contains(s, "stack=[log.newStackError(errors_test.go:"), s)
+}
+
+func TestErrJSON(t *testing.T) {
+	b, err := json.Marshal(Err(fmt.Errorf("read: %w", errors.New("eof"))))
+	assert.Nil(t, err)
+	var v struct {
+		Error  string
+		Type   string
+		Causes []struct{ Error, Type string }
+		Stack  []struct {
+			Func string
+			File string
+			Line int
+		}
+	}
+	assert.Nil(t, json.Unmarshal(b, &v))
+	assert.Equal(t, "read: eof", v.Error)
+	assert.Equal(t, "*fmt.wrapError", v.Type)
+	assert.Equal(t, 1, len(v.Causes))
+	assert.Equal(t, "eof", v.Causes[0].Error)
+	assert.True(t, len(v.Stack) > 0)
+	assert.Equal(t, "log.TestErrJSON", v.Stack[0].Func)
+}
+
+func TestWithError(t *testing.T) {
+	SetErrorStackDepth(1)
+	defer SetErrorStackDepth(5)
+
+	w := new(bytes.Buffer)
+	initMockLogger(w, true)
+	With(errors.New("boom")).Error("failed")
+	got := w.String()
+	assert.True(t, strings.HasPrefix(got, "boom (type=*errors.errorString, stack=[log.TestWithError(errors_test.go:"), got)
+
+	w.Reset()
+	WithJSON(errors.New("boom")).Error("")
+	assert.True(t, strings.HasPrefix(w.String(), `{"error":"boom","type":"*errors.errorString","stack":[{"func":"log.TestWithError"`), w.String())
+
+	w.Reset()
+	With(M{"err": errors.New("boom"), "id": 1}).Error("failed")
+	assert.True(t, strings.Contains(w.String(), "boom (type=*errors.errorString, stack=[log.TestWithError(errors_test.go:"), w.String())
+
+	w.Reset()
+	WithJSON(map[string]interface{}{"err": errors.New("boom")}, "x").Error("")
+	assert.True(t, strings.Contains(w.String(), `"err":{"error":"boom","type":"*errors.errorString","stack":[{"func":"log.TestWithError"`), w.String())
+
+	// errors nested deeper aren't wrapped
+	w.Reset()
+	WithJSON(M{"m": M{"err": errors.New("boom")}}).Error("")
+	assert.True(t, strings.HasPrefix(w.String(), `{"m":{"err":{}}}`), w.String())
+}
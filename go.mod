@@ -3,7 +3,8 @@ module github.com/mkideal/log
 go 1.14
 
 require (
-	github.com/mattn/go-colorable v0.1.8 // indirect
-	github.com/mattn/go-isatty v0.0.13 // indirect
-	github.com/stretchr/testify v1.7.0 // indirect
+	github.com/mattn/go-colorable v0.1.8
+	github.com/mattn/go-isatty v0.0.13
+	github.com/stretchr/testify v1.7.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -32,9 +32,9 @@ func (il IfLogger) ElseIf(ok bool) IfLogger {
 // With implements Context.With method
 func (il IfLogger) With(values ...interface{}) ContextLogger {
 	if len(values) == 1 {
-		return &contextLogger{isTrue: il.ok(), data: values[0]}
+		return &contextLogger{isTrue: il.ok(), data: errorValue(values[0], 1)}
 	}
-	return &contextLogger{isTrue: il.ok(), data: values}
+	return &contextLogger{isTrue: il.ok(), data: errorValues(values, 1)}
 }
 
 // WithJSON implements Context.WithJSON method
@@ -52,42 +52,49 @@ func (il IfLogger) SetFormatter(f Formatter) ContextLogger {
 
 func (il IfLogger) Trace(format string, args ...interface{}) IfLogger {
 	if il.ok() {
-		glogger.Trace(1, format, args...)
+		glogger().Trace(1, format, args...)
 	}
 	return il
 }
 
 func (il IfLogger) Debug(format string, args ...interface{}) IfLogger {
 	if il.ok() {
-		glogger.Debug(1, format, args...)
+		glogger().Debug(1, format, args...)
 	}
 	return il
 }
 
 func (il IfLogger) Info(format string, args ...interface{}) IfLogger {
 	if il.ok() {
-		glogger.Info(1, format, args...)
+		glogger().Info(1, format, args...)
 	}
 	return il
 }
 
 func (il IfLogger) Warn(format string, args ...interface{}) IfLogger {
 	if il.ok() {
-		glogger.Warn(1, format, args...)
+		glogger().Warn(1, format, args...)
 	}
 	return il
 }
 
 func (il IfLogger) Error(format string, args ...interface{}) IfLogger {
 	if il.ok() {
-		glogger.Error(1, format, args...)
+		glogger().Error(1, format, args...)
 	}
 	return il
 }
 
 func (il IfLogger) Fatal(format string, args ...interface{}) IfLogger {
 	if il.ok() {
-		glogger.Fatal(1, format, args...)
+		glogger().Fatal(1, format, args...)
+	}
+	return il
+}
+
+func (il IfLogger) Panic(format string, args ...interface{}) IfLogger {
+	if il.ok() {
+		panicf(1, format, args...)
 	}
 	return il
 }
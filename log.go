@@ -8,12 +8,15 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/mkideal/log/logger"
 	"github.com/mkideal/log/provider"
 )
 
 const (
+	LvPANIC = logger.PANIC
 	LvFATAL = logger.FATAL
 	LvERROR = logger.ERROR
 	LvWARN  = logger.WARN
@@ -34,22 +37,64 @@ func ParseLevel(s string) (logger.Level, bool) { return logger.ParseLevel(s) }
 // MustParseLevel is similar to ParseLevel, but panics if parse failed
 func MustParseLevel(s string) logger.Level { return logger.MustParseLevel(s) }
 
+// ErrUnsupported is returned if the global logger doesn't support the operation
+var ErrUnsupported = errors.New("unsupported by global logger")
+
 // global logger
-var glogger = logger.NewStdLogger()
+var (
+	gloggerMu    sync.Mutex   // serializes replacing of global logger
+	gloggerValue atomic.Value // gloggerHolder
+)
+
+// gloggerHolder holds the global logger such that atomic.Value always stores the same type
+type gloggerHolder struct {
+	logger.Logger
+}
+
+func init() {
+	gloggerValue.Store(gloggerHolder{logger.NewStdLogger()})
+}
+
+func glogger() logger.Logger {
+	return gloggerValue.Load().(gloggerHolder).Logger
+}
 
 // Uninit uninits log package
 func Uninit(err error) {
-	glogger.Quit()
+	gloggerMu.Lock()
+	defer gloggerMu.Unlock()
+	glogger().Quit()
 }
 
-// InitWithLogger inits global logger with a specified logger
+// InitWithLogger inits global logger with a specified logger.
+// The new logger is started before the old logger quits, so it's safe to be
+// called concurrently with logging functions.
 func InitWithLogger(l logger.Logger) error {
-	glogger.Quit()
-	glogger = l
-	glogger.Run()
+	gloggerMu.Lock()
+	defer gloggerMu.Unlock()
+	old := glogger()
+	if old == l {
+		return nil
+	}
+	l.Run()
+	gloggerValue.Store(gloggerHolder{l})
+	old.Quit()
 	return nil
 }
 
+// SwapLogger replaces global logger with l and returns the previous one. Unlike
+// InitWithLogger, the previous logger isn't quit, so it can be restored later.
+func SwapLogger(l logger.Logger) logger.Logger {
+	gloggerMu.Lock()
+	defer gloggerMu.Unlock()
+	old := glogger()
+	if old != l {
+		l.Run()
+		gloggerValue.Store(gloggerHolder{l})
+	}
+	return old
+}
+
 // InitWithProvider inits global logger(sync) with a specified provider
 func InitWithProvider(p logger.Provider) error {
 	l := logger.New(p)
@@ -70,7 +115,7 @@ func Init(providerTypes string, opts interface{}) error {
 	types := strings.Split(providerTypes, "/")
 	if len(types) == 0 || len(providerTypes) == 0 {
 		err := errors.New("empty providers")
-		glogger.Error(1, "init log error: %v", err)
+		glogger().Error(1, "init log error: %v", err)
 		return err
 	}
 	// gets opts string
@@ -99,7 +144,7 @@ func Init(providerTypes string, opts interface{}) error {
 		creator := logger.Lookup(typ)
 		if creator == nil {
 			err := errors.New("unregistered provider type: " + typ)
-			glogger.Error(1, "init log error: %v", err)
+			glogger().Error(1, "init log error: %v", err)
 			return err
 		}
 		p := creator(optsString)
@@ -162,49 +207,156 @@ func InitMultiFileAndConsole(rootdir, filename string, toStderrLevel logger.Leve
 	return InitWithProvider(p)
 }
 
-func NoHeader()                                { glogger.NoHeader() }
-func GetLevel() logger.Level                   { return glogger.GetLevel() }
-func SetLevel(level logger.Level)              { glogger.SetLevel(level) }
-func Trace(format string, args ...interface{}) { glogger.Trace(1, format, args...) }
-func Debug(format string, args ...interface{}) { glogger.Debug(1, format, args...) }
-func Info(format string, args ...interface{})  { glogger.Info(1, format, args...) }
-func Warn(format string, args ...interface{})  { glogger.Warn(1, format, args...) }
-func Error(format string, args ...interface{}) { glogger.Error(1, format, args...) }
-func Fatal(format string, args ...interface{}) { glogger.Fatal(1, format, args...) }
+// SetFlightRecorder enables flight recorder mode of global logger, or disables it if opts is nil
+func SetFlightRecorder(opts *logger.FlightRecorderOpts) error {
+	r, ok := glogger().(logger.FlightRecorder)
+	if !ok {
+		return ErrUnsupported
+	}
+	r.SetFlightRecorder(opts)
+	return nil
+}
+
+// SetFatalPolicy sets the policy of global logger applied after FATAL entries written
+func SetFatalPolicy(policy logger.FatalPolicy) error {
+	f, ok := glogger().(logger.FatalConfigurable)
+	if !ok {
+		return ErrUnsupported
+	}
+	f.SetFatalPolicy(policy)
+	return nil
+}
+
+// RegisterExitHook registers a function which is called before exiting caused by FATAL entry
+func RegisterExitHook(hook func()) { logger.RegisterExitHook(hook) }
+
+// SetStackOpts sets stack options of global logger
+func SetStackOpts(opts logger.StackOpts) error {
+	s, ok := glogger().(logger.StackConfigurable)
+	if !ok {
+		return ErrUnsupported
+	}
+	s.SetStackOpts(opts)
+	return nil
+}
+
+// Reopen reopens files of global logger, e.g. after the files moved by logrotate
+func Reopen() error {
+	r, ok := glogger().(logger.Reopener)
+	if !ok {
+		return ErrUnsupported
+	}
+	return r.Reopen()
+}
+
+// AddHook hooks the handler to global logger, the returned id is used by Unhook
+func AddHook(h logger.Handler, opts logger.HookOpts) (logger.HookID, error) {
+	hk, ok := glogger().(logger.Hooker)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	return hk.AddHook(h, opts), nil
+}
+
+// Unhook removes the hook from global logger, it returns false if the hook not found
+func Unhook(id logger.HookID) bool {
+	hk, ok := glogger().(logger.Hooker)
+	return ok && hk.Unhook(id)
+}
+
+// SetModuleLevel sets level of the module of global logger
+func SetModuleLevel(module string, level logger.Level) error {
+	ml, ok := glogger().(logger.ModuleLeveler)
+	if !ok {
+		return ErrUnsupported
+	}
+	ml.SetModuleLevel(module, level)
+	return nil
+}
+
+// SetHeaderFormat sets header format of global logger
+func SetHeaderFormat(format logger.HeaderFormat) error {
+	hf, ok := glogger().(logger.HeaderFormatter)
+	if !ok {
+		return ErrUnsupported
+	}
+	hf.SetHeaderFormat(format)
+	return nil
+}
+
+// SetSampling enables log sampling of global logger, or disables it if opts is nil
+func SetSampling(opts *logger.SamplingOpts) error {
+	s, ok := glogger().(logger.Sampler)
+	if !ok {
+		return ErrUnsupported
+	}
+	s.SetSampling(opts)
+	return nil
+}
+
+func NoHeader()                                { glogger().NoHeader() }
+func GetLevel() logger.Level                   { return glogger().GetLevel() }
+func SetLevel(level logger.Level)              { glogger().SetLevel(level) }
+func Trace(format string, args ...interface{}) { glogger().Trace(1, format, args...) }
+func Debug(format string, args ...interface{}) { glogger().Debug(1, format, args...) }
+func Info(format string, args ...interface{})  { glogger().Info(1, format, args...) }
+func Warn(format string, args ...interface{})  { glogger().Warn(1, format, args...) }
+func Error(format string, args ...interface{}) { glogger().Error(1, format, args...) }
+func Fatal(format string, args ...interface{}) { glogger().Fatal(1, format, args...) }
+func Panic(format string, args ...interface{}) { panicf(1, format, args...) }
+
+// panicf outputs panic-level logs by global logger, then panics. The logs are
+// error-level if global logger doesn't implement logger.Panicker.
+func panicf(calldepth int, format string, args ...interface{}) {
+	l := glogger()
+	if p, ok := l.(logger.Panicker); ok {
+		p.Panic(calldepth+1, format, args...)
+		return
+	}
+	l.Error(calldepth+1, format, args...)
+	if len(args) > 0 {
+		format = fmt.Sprintf(format, args...)
+	}
+	panic(format)
+}
 
 func Printf(calldepth int, level logger.Level, format string, args ...interface{}) {
 	switch level {
 	case LvTRACE:
-		glogger.Trace(calldepth, format, args...)
+		glogger().Trace(calldepth, format, args...)
 	case LvDEBUG:
-		glogger.Debug(calldepth, format, args...)
+		glogger().Debug(calldepth, format, args...)
 	case LvINFO:
-		glogger.Info(calldepth, format, args...)
+		glogger().Info(calldepth, format, args...)
 	case LvWARN:
-		glogger.Warn(calldepth, format, args...)
+		glogger().Warn(calldepth, format, args...)
 	case LvERROR:
-		glogger.Error(calldepth, format, args...)
+		glogger().Error(calldepth, format, args...)
 	case LvFATAL:
-		glogger.Fatal(calldepth, format, args...)
+		glogger().Fatal(calldepth, format, args...)
+	case LvPANIC:
+		panicf(calldepth, format, args...)
 	}
 }
 
 func Print(calldepth int, level logger.Level, args ...interface{}) {
-	if level <= glogger.GetLevel() {
+	if level <= glogger().GetLevel() {
 		msg := fmt.Sprint(args...)
 		switch level {
 		case LvTRACE:
-			glogger.Trace(calldepth, msg)
+			glogger().Trace(calldepth, msg)
 		case LvDEBUG:
-			glogger.Debug(calldepth, msg)
+			glogger().Debug(calldepth, msg)
 		case LvINFO:
-			glogger.Info(calldepth, msg)
+			glogger().Info(calldepth, msg)
 		case LvWARN:
-			glogger.Warn(calldepth, msg)
+			glogger().Warn(calldepth, msg)
 		case LvERROR:
-			glogger.Error(calldepth, msg)
+			glogger().Error(calldepth, msg)
 		case LvFATAL:
-			glogger.Fatal(calldepth, msg)
+			glogger().Fatal(calldepth, msg)
+		case LvPANIC:
+			panicf(calldepth, msg)
 		}
 	}
 }
@@ -246,7 +398,7 @@ func HTTPHandlerSetLevel() http.Handler {
 // (NOTE): set level to INFO if parse failed
 func SetLevelFromString(s string) logger.Level {
 	level, _ := ParseLevel(s)
-	glogger.SetLevel(level)
+	glogger().SetLevel(level)
 	return level
 }
 
@@ -259,18 +411,27 @@ func If(ok bool) IfLogger {
 	}
 }
 
-// With returns a ContextLogger
+// With returns a ContextLogger, error values are wrapped by Err
 func With(values ...interface{}) ContextLogger {
 	if len(values) == 1 {
-		return &contextLogger{isTrue: true, data: values[0]}
+		return &contextLogger{isTrue: true, data: errorValue(values[0], 1)}
 	}
-	return &contextLogger{isTrue: true, data: values}
+	return &contextLogger{isTrue: true, data: errorValues(values, 1)}
+}
+
+// Module returns a ContextLogger of the module, entries are prefixed with "[module] "
+// and filtered by the level of module, e.g.
+//
+//	log.SetModuleLevel("db", log.LvDEBUG)
+//	log.Module("db/sql").Debug("query %s", sql)
+func Module(name string) ContextLogger {
+	return &contextLogger{isTrue: true, module: name}
 }
 
 // WithJSON returns a ContextLogger using JSONFormatter
 func WithJSON(values ...interface{}) ContextLogger {
 	if len(values) == 1 {
-		return &contextLogger{isTrue: true, data: values[0], formatter: jsonFormatter}
+		return &contextLogger{isTrue: true, data: errorValue(values[0], 1), formatter: jsonFormatter}
 	}
-	return &contextLogger{isTrue: true, data: values, formatter: jsonFormatter}
+	return &contextLogger{isTrue: true, data: errorValues(values, 1), formatter: jsonFormatter}
 }
@@ -1,9 +1,12 @@
 package log
 
 import (
+	"bytes"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/mkideal/log/logger"
 	"github.com/mkideal/log/provider"
 	"github.com/stretchr/testify/assert"
 )
@@ -80,3 +83,21 @@ func TestSetLevelFromString(t *testing.T) {
 	assert.Equal(t, LvINFO, SetLevelFromString("invalid"))
 	assert.Equal(t, LvINFO, GetLevel())
 }
+
+// plainLogger hides optional interfaces of the embedded logger, e.g. logger.Panicker
+type plainLogger struct {
+	logger.Logger
+}
+
+func TestPanic(t *testing.T) {
+	w := new(bytes.Buffer)
+	initMockLogger(w, false)
+	assert.PanicsWithValue(t, "panic 1", func() { Panic("panic %d", 1) })
+	assert.True(t, strings.HasPrefix(w.String(), "panic 1\n"))
+
+	w.Reset()
+	old := SwapLogger(plainLogger{glogger()})
+	defer SwapLogger(old)
+	assert.PanicsWithValue(t, "panic 2", func() { Panic("panic %d", 2) })
+	assert.True(t, strings.HasPrefix(w.String(), "panic 2\n"))
+}
@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"runtime"
+	"time"
+)
+
+// Deterministic is a logger whose clock and caller can be replaced, e.g. to compare
+// output with golden files
+type Deterministic interface {
+	// SetClock sets the function which returns time of entries, time.Now is used if nil
+	SetClock(now func() time.Time)
+	// SetCaller sets the function which returns file and line of entries, runtime.Caller(calldepth)
+	// called in the function returns the call site of logging, it's used if caller is nil
+	SetCaller(caller func(calldepth int) (file string, line int))
+}
+
+// SetClock implements Deterministic interface
+func (l *logger) SetClock(now func() time.Time) {
+	l.clock.Store(now)
+}
+
+// SetCaller implements Deterministic interface
+func (l *logger) SetCaller(caller func(calldepth int) (file string, line int)) {
+	l.callerFunc.Store(caller)
+}
+
+func (l *logger) now() time.Time {
+	if now := l.clock.Load().(func() time.Time); now != nil {
+		return now()
+	}
+	return time.Now()
+}
+
+func (l *logger) caller(calldepth int) (string, int) {
+	if caller := l.callerFunc.Load().(func(int) (string, int)); caller != nil {
+		return caller(calldepth + 2)
+	}
+	_, file, line, ok := runtime.Caller(calldepth + 1)
+	if !ok {
+		return "???", 0
+	}
+	return file, line
+}
@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Duration is a time.Duration used in options, it's encoded as a string like "1m30s"
+// in JSON, and a number in JSON is decoded as seconds.
+type Duration time.Duration
+
+var errInvalidDuration = errors.New("invalid duration")
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch x := v.(type) {
+	case float64:
+		*d = Duration(x * float64(time.Second))
+	case string:
+		dur, err := time.ParseDuration(x)
+		if err != nil {
+			return err
+		}
+		*d = Duration(dur)
+	default:
+		return errInvalidDuration
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"bytes"
+	"time"
 )
 
 type entry struct {
@@ -11,9 +12,17 @@ type entry struct {
 	level              Level
 	headerLength       int
 	quit               bool
+	call               func() error // not nil if it's a command called by the writing goroutine
 	timestamp          int64
+	time               time.Time
+	file               string
+	line               int
+	module             string
 	bodyBegin, bodyEnd int
 	descBegin, descEnd int
+	value              interface{}
+	done               chan struct{} // closed after written if not nil
+	stack              Frames
 }
 
 func (e *entry) Reset() {
@@ -23,7 +32,14 @@ func (e *entry) Reset() {
 	e.descBegin = 0
 	e.descEnd = 0
 	e.quit = false
+	e.call = nil
 	e.headerLength = 0
+	e.file = ""
+	e.line = 0
+	e.module = ""
+	e.value = nil
+	e.done = nil
+	e.stack = nil
 }
 
 func (e *entry) clone() *entry {
@@ -32,21 +48,33 @@ func (e *entry) clone() *entry {
 		headerLength: e.headerLength,
 		quit:         e.quit,
 		timestamp:    e.timestamp,
+		time:         e.time,
+		file:         e.file,
+		line:         e.line,
+		module:       e.module,
 		bodyBegin:    e.bodyBegin,
 		bodyEnd:      e.bodyEnd,
 		descBegin:    e.descBegin,
 		descEnd:      e.descEnd,
+		value:        e.value,
+		stack:        e.stack,
 	}
 	e2.Buffer = bytes.Buffer{}
 	e2.Buffer.Write(e.Bytes())
 	return e2
 }
 
-func (e *entry) Level() Level     { return e.level }
-func (e *entry) Timestamp() int64 { return e.timestamp }
-func (e *entry) Body() []byte     { return e.Bytes()[e.bodyBegin:e.bodyEnd] }
-func (e *entry) Desc() []byte     { return e.Bytes()[e.descBegin:e.descEnd] }
-func (e *entry) Clone() Entry     { return e.clone() }
+func (e *entry) Level() Level          { return e.level }
+func (e *entry) Timestamp() int64      { return e.timestamp }
+func (e *entry) Time() time.Time       { return e.time }
+func (e *entry) Caller() (string, int) { return e.file, e.line }
+func (e *entry) Module() string        { return e.module }
+func (e *entry) HeaderLength() int     { return e.headerLength }
+func (e *entry) Body() []byte          { return e.Bytes()[e.bodyBegin:e.bodyEnd] }
+func (e *entry) Desc() []byte          { return e.Bytes()[e.descBegin:e.descEnd] }
+func (e *entry) Value() interface{}    { return e.value }
+func (e *entry) Stack() Frames         { return e.stack }
+func (e *entry) Clone() Entry          { return e.clone() }
 
 const digits = "0123456789"
 
@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FatalPolicy represents what to do after a FATAL entry has been written.
+//
+// By default, registered exit hooks are run, providers are flushed and closed,
+// and then the process exits with code 1.
+type FatalPolicy struct {
+	ExitCode     int            // exit code(default: 1), 0 is reserved for the default since FATAL never exits cleanly
+	FlushTimeout time.Duration  // max time to wait for flushing providers(default: 3s), negative means no flushing
+	Panic        bool           // panics with the message instead of exiting, exit hooks and flushing are skipped
+	Exit         func(code int) // exit function(default: os.Exit)
+}
+
+// FatalConfigurable is a logger whose FATAL behavior is configurable
+type FatalConfigurable interface {
+	// SetFatalPolicy sets the policy applied after FATAL entries written
+	SetFatalPolicy(policy FatalPolicy)
+}
+
+var (
+	exitHooksMu sync.Mutex
+	exitHooks   []func()
+)
+
+// RegisterExitHook registers a function which is called before exiting caused by FATAL entry
+func RegisterExitHook(hook func()) {
+	exitHooksMu.Lock()
+	defer exitHooksMu.Unlock()
+	exitHooks = append(exitHooks, hook)
+}
+
+func runExitHooks() {
+	exitHooksMu.Lock()
+	hooks := append([]func(){}, exitHooks...)
+	exitHooksMu.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// ExitRecorder records exit calls instead of exiting, it's useful for testing FATAL paths:
+//
+//	var r logger.ExitRecorder
+//	l.SetFatalPolicy(r.Policy())
+type ExitRecorder struct {
+	mu    sync.Mutex
+	codes []int
+}
+
+// Exit records the exit code
+func (r *ExitRecorder) Exit(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes = append(r.codes, code)
+}
+
+// Codes returns all recorded exit codes
+func (r *ExitRecorder) Codes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.codes...)
+}
+
+// Policy returns a FatalPolicy which records exit calls without flushing providers
+func (r *ExitRecorder) Policy() FatalPolicy {
+	return FatalPolicy{FlushTimeout: -1, Exit: r.Exit}
+}
+
+// terminate applies the policy after a FATAL or PANIC entry has been written,
+// flush flushes and closes providers.
+func terminate(policy FatalPolicy, level Level, msg string, flush func()) {
+	if level == PANIC || policy.Panic {
+		panic(msg)
+	}
+	runExitHooks()
+	if flush != nil && policy.FlushTimeout >= 0 {
+		timeout := policy.FlushTimeout
+		if timeout == 0 {
+			timeout = maxWaitTimeForImportantLevel
+		}
+		done := make(chan struct{})
+		go func() {
+			flush()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(timeout):
+		}
+	}
+	code := policy.ExitCode
+	if code == 0 {
+		code = 1
+	}
+	if policy.Exit != nil {
+		policy.Exit(code)
+	} else {
+		os.Exit(code)
+	}
+}
+
+// SetFatalPolicy implements FatalConfigurable interface
+func (l *logger) SetFatalPolicy(policy FatalPolicy) {
+	l.fatalPolicy.Store(policy)
+}
+
+// flush writes all queued entries and closes the provider
+func (l *logger) flush() {
+	if l.async && atomic.LoadInt32(&l.running) != 0 {
+		l.Quit()
+		return
+	}
+	l.writeLocker.Lock()
+	defer l.writeLocker.Unlock()
+	l.provider.Close()
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFatalPolicy(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		p := newMockProvider()
+		l := newLogger(p, async)
+		l.Run()
+		var r ExitRecorder
+		l.SetFatalPolicy(r.Policy())
+
+		l.Fatal(0, "fatal %d", 1)
+		assert.Equal(t, []int{1}, r.Codes())
+		assert.True(t, strings.HasPrefix(p.data.String(), "fatal 1\n========= BEGIN STACK TRACE ========="))
+
+		policy := r.Policy()
+		policy.ExitCode = 2
+		l.SetFatalPolicy(policy)
+		l.Fatal(0, "fatal 2")
+		assert.Equal(t, []int{1, 2}, r.Codes())
+		l.Quit()
+	}
+}
+
+func TestFatalExitHooks(t *testing.T) {
+	l := newLogger(newMockProvider(), false)
+	called := false
+	RegisterExitHook(func() { called = true })
+	defer func() {
+		exitHooksMu.Lock()
+		exitHooks = nil
+		exitHooksMu.Unlock()
+	}()
+	var r ExitRecorder
+	l.SetFatalPolicy(FatalPolicy{Exit: r.Exit})
+	l.Fatal(0, "fatal")
+	assert.True(t, called)
+	assert.Equal(t, []int{1}, r.Codes())
+}
+
+func TestPanic(t *testing.T) {
+	p := newMockProvider()
+	l := newLogger(p, false)
+	assert.PanicsWithValue(t, "panic 1", func() { l.Panic(0, "panic %d", 1) })
+	assert.True(t, strings.HasPrefix(p.data.String(), "panic 1\n"))
+
+	l.SetFatalPolicy(FatalPolicy{Panic: true})
+	assert.PanicsWithValue(t, "fatal", func() { l.Fatal(0, "fatal") })
+
+	std := NewStdLogger().(*stdLogger)
+	std.SetFatalPolicy(FatalPolicy{Panic: true})
+	assert.PanicsWithValue(t, "std fatal", func() { std.Fatal(0, "std fatal") })
+}
@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"reflect"
+	"strings"
+)
+
+// maxSnapshotDepth limits the depth of copied values, deeper values are shared,
+// e.g. values which refer to themselves
+const maxSnapshotDepth = 16
+
+// snapshot returns a copy of the context value v whose maps, slices, pointers and
+// exported fields of structs are copied recursively, so types of values are kept.
+// The entry keeps the copy instead of v, since v may be modified by the caller while
+// the entry is written by another goroutine.
+func snapshot(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return copyValue(reflect.ValueOf(v), maxSnapshotDepth).Interface()
+}
+
+func copyValue(v reflect.Value, depth int) reflect.Value {
+	if depth == 0 {
+		return v
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(copyValue(v.Elem(), depth-1))
+		return c
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(copyValue(v.Elem(), depth-1))
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			c.SetMapIndex(iter.Key(), copyValue(iter.Value(), depth-1))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(c, v)
+		copyElems(c, depth)
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		reflect.Copy(c, v)
+		copyElems(c, depth)
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < c.NumField(); i++ {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(copyValue(f, depth-1))
+			}
+		}
+		return c
+	}
+	return v
+}
+
+// copyElems copies elements of slice or array c which refer to other values
+func copyElems(c reflect.Value, depth int) {
+	switch c.Type().Elem().Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		for i := 0; i < c.Len(); i++ {
+			c.Index(i).Set(copyValue(c.Index(i), depth-1))
+		}
+	}
+}
+
+// LookupField looks up field key in the context value v.
+// v can be a map with string keys, a struct(or pointer to struct) or a slice of them.
+// Exported fields of struct are matched by name or json tag.
+func LookupField(v interface{}, key string) (interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	return lookupField(reflect.ValueOf(v), key)
+}
+
+func lookupField(v reflect.Value, key string) (interface{}, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		fv := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+		if !fv.IsValid() {
+			return nil, false
+		}
+		return fv.Interface(), true
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := f.Name
+			if tag := f.Tag.Get("json"); tag != "" {
+				if i := strings.IndexByte(tag, ','); i >= 0 {
+					tag = tag[:i]
+				}
+				if tag != "" && tag != "-" {
+					name = tag
+				}
+			}
+			if name == key {
+				return v.Field(i).Interface(), true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return nil, false
+		}
+		for i := 0; i < v.Len(); i++ {
+			if fv, ok := lookupField(v.Index(i), key); ok {
+				return fv, true
+			}
+		}
+	}
+	return nil, false
+}
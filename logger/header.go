@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+)
+
+// HeaderFormat represents format of entry headers
+type HeaderFormat int32
+
+const (
+	HeaderDefault HeaderFormat = iota // [L yyyy/MM/dd hh:mm:ss.uuu file:line]
+	HeaderShort                       // [L hh:mm:ss.uuu file:line]
+	HeaderNone                        // no header
+)
+
+var ErrUnrecognizedHeaderFormat = errors.New("unrecognized header format")
+
+// String returns a serialized string of header format
+func (f HeaderFormat) String() string {
+	switch f {
+	case HeaderDefault:
+		return "default"
+	case HeaderShort:
+		return "short"
+	case HeaderNone:
+		return "none"
+	}
+	return "invalid"
+}
+
+// ParseHeaderFormat parses header format from string: default/short/none
+func ParseHeaderFormat(s string) (HeaderFormat, error) {
+	switch strings.ToLower(s) {
+	case "", "default":
+		return HeaderDefault, nil
+	case "short":
+		return HeaderShort, nil
+	case "none":
+		return HeaderNone, nil
+	}
+	return HeaderDefault, ErrUnrecognizedHeaderFormat
+}
+
+// HeaderFormatter is a logger whose header format is configurable
+type HeaderFormatter interface {
+	// SetHeaderFormat sets format of entry headers
+	SetHeaderFormat(format HeaderFormat)
+}
+
+// SetHeaderFormat implements HeaderFormatter interface
+func (l *logger) SetHeaderFormat(format HeaderFormat) {
+	atomic.StoreInt32(&l.headerFormat, int32(format))
+}
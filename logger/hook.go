@@ -0,0 +1,190 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// HookOpts represents options of a hook
+type HookOpts struct {
+	Levels    []Level         // levels of entries handled, all levels if empty
+	Async     bool            // handles entries in its own goroutine, entries are dropped if the queue is full
+	QueueSize int             // capacity of the queue of async hook(default: 1024)
+	OnError   func(err error) // called with errors returned by the handler or recovered panics
+}
+
+// HookID identifies a hook added by AddHook
+type HookID uint64
+
+// Hooker is a logger whose hooks can be added and removed at any time
+type Hooker interface {
+	// AddHook hooks h with options and returns the id used by Unhook.
+	// Sync handlers must not retain the entry after Handle returned,
+	// async handlers receive clones of entries.
+	AddHook(h Handler, opts HookOpts) HookID
+	// Unhook removes the hook, it returns false if the hook not found.
+	// Async hooks are stopped after queued entries handled.
+	Unhook(id HookID) bool
+}
+
+// hook is a handler added to the logger
+type hook struct {
+	id      HookID
+	handler Handler
+	levels  uint32 // bit level+1 is set if the level is handled, 0 means all levels
+	onError func(err error)
+
+	counters *counters
+
+	// queue is nil if the hook is sync
+	mu     sync.RWMutex
+	closed bool
+	queue  chan Entry
+	done   chan struct{}
+}
+
+func newHook(id HookID, h Handler, opts HookOpts, c *counters) *hook {
+	hk := &hook{
+		id:       id,
+		handler:  h,
+		onError:  opts.OnError,
+		counters: c,
+	}
+	for _, level := range opts.Levels {
+		hk.levels |= 1 << uint(level+1)
+	}
+	if opts.Async {
+		if opts.QueueSize <= 0 {
+			opts.QueueSize = 1024
+		}
+		hk.queue = make(chan Entry, opts.QueueSize)
+		hk.done = make(chan struct{})
+		go hk.run()
+	}
+	return hk
+}
+
+func (hk *hook) run() {
+	defer close(hk.done)
+	for e := range hk.queue {
+		hk.call(e)
+	}
+}
+
+// handle handles e in the current goroutine if the hook is sync, otherwise a clone
+// of e is queued
+func (hk *hook) handle(e Entry) {
+	if hk.levels != 0 && hk.levels&(1<<uint(e.Level()+1)) == 0 {
+		return
+	}
+	if hk.queue == nil {
+		hk.call(e)
+		return
+	}
+	hk.mu.RLock()
+	defer hk.mu.RUnlock()
+	if hk.closed {
+		return
+	}
+	select {
+	case hk.queue <- e.Clone():
+	default:
+		atomic.AddUint64(&hk.counters.droppedHook, 1)
+	}
+}
+
+// call calls the handler, panics are recovered and reported as errors
+func (hk *hook) call(e Entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			hk.failed(fmt.Errorf("hook panic: %v", r))
+		}
+	}()
+	if err := hk.handler.Handle(e); err != nil {
+		hk.failed(err)
+	}
+}
+
+func (hk *hook) failed(err error) {
+	atomic.AddUint64(&hk.counters.hookErrors, 1)
+	if hk.onError != nil {
+		hk.onError(err)
+	}
+}
+
+// stop stops the async hook after queued entries handled
+func (hk *hook) stop() {
+	if hk.queue == nil {
+		return
+	}
+	hk.mu.Lock()
+	if hk.closed {
+		hk.mu.Unlock()
+		return
+	}
+	hk.closed = true
+	close(hk.queue)
+	hk.mu.Unlock()
+	<-hk.done
+}
+
+func (l *logger) hooks() []*hook {
+	return l.hookList.Load().([]*hook)
+}
+
+// handle calls hooks with the entry written
+func (l *logger) handle(e *entry) {
+	for _, hk := range l.hooks() {
+		hk.handle(e)
+	}
+}
+
+// Hook implements HookableLogger interface, it's a shortcut of AddHook(h, HookOpts{})
+func (l *logger) Hook(h Handler) {
+	l.AddHook(h, HookOpts{})
+}
+
+// AddHook implements Hooker interface
+func (l *logger) AddHook(h Handler, opts HookOpts) HookID {
+	l.hooksMu.Lock()
+	defer l.hooksMu.Unlock()
+	l.lastHookID++
+	old := l.hooks()
+	hooks := make([]*hook, len(old), len(old)+1)
+	copy(hooks, old)
+	hooks = append(hooks, newHook(l.lastHookID, h, opts, l.counters))
+	l.hookList.Store(hooks)
+	return l.lastHookID
+}
+
+// Unhook implements Hooker interface
+func (l *logger) Unhook(id HookID) bool {
+	l.hooksMu.Lock()
+	old := l.hooks()
+	var removed *hook
+	hooks := make([]*hook, 0, len(old))
+	for _, hk := range old {
+		if hk.id == id {
+			removed = hk
+		} else {
+			hooks = append(hooks, hk)
+		}
+	}
+	l.hookList.Store(hooks)
+	l.hooksMu.Unlock()
+	if removed == nil {
+		return false
+	}
+	removed.stop()
+	return true
+}
+
+// stopHooks removes async hooks after queued entries handled
+func (l *logger) stopHooks() {
+	for _, hk := range l.hooks() {
+		if hk.queue != nil {
+			l.Unhook(hk.id)
+		}
+	}
+}
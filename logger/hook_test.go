@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHandler records descriptions of entries
+type recordingHandler struct {
+	mu    sync.Mutex
+	descs []string
+}
+
+func (h *recordingHandler) Handle(e Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.descs = append(h.descs, string(e.Desc()))
+	return nil
+}
+
+func (h *recordingHandler) get() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.descs...)
+}
+
+func TestHookOpts(t *testing.T) {
+	l := newLogger(newMockProvider(), true)
+	l.SetLevel(TRACE)
+	go l.Run()
+
+	var (
+		all    = new(recordingHandler)
+		errs   = new(recordingHandler)
+		async  = new(recordingHandler)
+		failed []string
+	)
+	l.AddHook(all, HookOpts{})
+	errID := l.AddHook(errs, HookOpts{Levels: []Level{ERROR, FATAL}})
+	l.AddHook(async, HookOpts{Async: true, Levels: []Level{INFO}})
+	l.AddHook(HandlerFunc(func(e Entry) error {
+		switch e.Level() {
+		case WARN:
+			return errors.New("warn")
+		case ERROR:
+			panic("error")
+		}
+		return nil
+	}), HookOpts{OnError: func(err error) { failed = append(failed, err.Error()) }})
+
+	l.Info(0, "info")
+	l.Warn(0, "warn")
+	l.Error(0, "error")
+	// waits until queued entries written
+	l.Reopen()
+	assert.True(t, l.Unhook(errID))
+	assert.False(t, l.Unhook(errID))
+	l.Error(0, "unhooked")
+	l.Quit()
+
+	assert.Equal(t, []string{"info", "warn", "error", "unhooked"}, all.get())
+	assert.Equal(t, []string{"error"}, errs.get())
+	assert.Equal(t, []string{"info"}, async.get())
+	assert.Equal(t, []string{"warn", "hook panic: error", "hook panic: error"}, failed)
+	assert.Equal(t, uint64(3), l.Stats().HookErrors)
+}
+
+func TestAsyncHookDropped(t *testing.T) {
+	l := newLogger(newMockProvider(), false)
+	l.SetLevel(TRACE)
+	var (
+		unblock = make(chan struct{})
+		started = make(chan struct{}, 1)
+		handled = new(recordingHandler)
+	)
+	l.AddHook(HandlerFunc(func(e Entry) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-unblock
+		return handled.Handle(e)
+	}), HookOpts{Async: true, QueueSize: 1})
+	l.Info(0, "0")
+	<-started
+	for i := 1; i < 4; i++ {
+		l.Info(0, "%d", i)
+	}
+	close(unblock)
+	l.Quit()
+	// the first entry is being handled, the second is queued and others are dropped
+	assert.Equal(t, []string{"0", "1"}, handled.get())
+	assert.Equal(t, uint64(2), l.Stats().Dropped[dropHookQueue])
+}
+
+func TestHookConcurrently(t *testing.T) {
+	l := newLogger(newMockProvider(), true)
+	l.SetLevel(TRACE)
+	go l.Run()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Info(0, "%d-%d", i, j)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				id := l.AddHook(new(recordingHandler), HookOpts{Async: j%2 == 0})
+				l.Unhook(id)
+			}
+		}()
+	}
+	wg.Wait()
+	l.Quit()
+	assert.Equal(t, 0, len(l.hooks()))
+	assert.Equal(t, uint64(400), l.Stats().Entries[INFO])
+}
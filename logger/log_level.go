@@ -19,6 +19,10 @@ const (
 	NumLevel              // 6 log levels
 )
 
+// PANIC logs and panics. Like FATAL, it's never filtered by level.
+// It's not counted in NumLevel for compatibility.
+const PANIC Level = -1
+
 var ErrUnrecognizedLogLevel = errors.New("unrecognized log level")
 
 // Set implements flag.Value interface such that you can use level  as a command as following:
@@ -47,6 +51,8 @@ func (level Level) Literal() string {
 // String returns a serialized string of level
 func (level Level) String() string {
 	switch level {
+	case PANIC:
+		return "PANIC"
 	case FATAL:
 		return "FATAL"
 	case ERROR:
@@ -91,6 +97,8 @@ func (level Level) MoreVerboseThan(other Level) bool { return level > other }
 func ParseLevel(s string) (lv Level, ok bool) {
 	s = strings.ToUpper(s)
 	switch s {
+	case "PANIC", "P", PANIC.Literal():
+		return PANIC, true
 	case "FATAL", "F", FATAL.Literal():
 		return FATAL, true
 	case "ERROR", "E", ERROR.Literal():
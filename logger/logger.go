@@ -2,7 +2,6 @@ package logger
 
 import (
 	"fmt"
-	"os"
 	"runtime"
 	"strings"
 	"sync"
@@ -37,14 +36,33 @@ type Logger interface {
 	Warn(calldepth int, format string, args ...interface{})
 	// Error outputs error-level logs
 	Error(calldepth int, format string, args ...interface{})
-	// Fatal outputs fatal-level logs
+	// Fatal outputs fatal-level logs, then applies the fatal policy
 	Fatal(calldepth int, format string, args ...interface{})
 }
 
+// Panicker is an optional interface of Logger which supports level PANIC,
+// loggers of this package implement it
+type Panicker interface {
+	// Panic outputs panic-level logs, then panics
+	Panic(calldepth int, format string, args ...interface{})
+}
+
 type With interface {
 	LogWith(level Level, calldepth int, data []byte, format string, args ...interface{})
 }
 
+// Context represents the context of a logging entry created by log.With
+type Context struct {
+	Module string      // module name, empty if no module
+	Value  interface{} // original context value, the entry keeps a snapshot of it
+	Data   []byte      // formatted context value
+}
+
+// ContextWith is a With which keeps a snapshot of the context value in the entry
+type ContextWith interface {
+	LogContext(level Level, calldepth int, ctx Context, format string, args ...interface{})
+}
+
 // Entry represents a logging entry
 type Entry interface {
 	Level() Level
@@ -54,55 +72,163 @@ type Entry interface {
 	Clone() Entry
 }
 
+// DetailedEntry is an optional interface of Entry which has details of the entry,
+// entries created by loggers of this package implement it. Use Detailed to get
+// details of any entry.
+type DetailedEntry interface {
+	Entry
+	Time() time.Time
+	// Caller returns the file name and line number of caller, empty file if no header
+	Caller() (file string, line int)
+	// Module returns the module name, empty if no module
+	Module() string
+	// Bytes returns the whole entry which is written to provider
+	Bytes() []byte
+	// HeaderLength returns length of header in Bytes
+	HeaderLength() int
+	// Value returns a snapshot of the context value taken while logging, it's a copy
+	// of the value with the same type, nil if no context
+	Value() interface{}
+	// Stack returns the call stack attached to the entry, nil if no stack
+	Stack() Frames
+}
+
+// Detailed returns e as a DetailedEntry. If e doesn't implement it, the time is
+// made from Timestamp, Bytes returns Desc and other details are empty.
+func Detailed(e Entry) DetailedEntry {
+	if d, ok := e.(DetailedEntry); ok {
+		return d
+	}
+	return basicEntry{e}
+}
+
+// basicEntry is an Entry without details
+type basicEntry struct {
+	Entry
+}
+
+func (e basicEntry) Time() time.Time                 { return time.Unix(e.Timestamp(), 0) }
+func (e basicEntry) Caller() (file string, line int) { return "", 0 }
+func (e basicEntry) Module() string                  { return "" }
+func (e basicEntry) Bytes() []byte                   { return e.Desc() }
+func (e basicEntry) HeaderLength() int               { return 0 }
+func (e basicEntry) Value() interface{}              { return nil }
+func (e basicEntry) Stack() Frames                   { return nil }
+
+// EntryWriter is an optional interface of Provider which receives the whole entry
+// instead of formatted bytes, e.g. to format entries as JSON
+type EntryWriter interface {
+	WriteEntry(e Entry) error
+}
+
+// WriteEntry writes entry e to provider p, EntryWriter is preferred
+func WriteEntry(p Provider, e Entry) error {
+	if w, ok := p.(EntryWriter); ok {
+		return w.WriteEntry(e)
+	}
+	d := Detailed(e)
+	return p.Write(d.Level(), d.HeaderLength(), d.Bytes())
+}
+
+// BatchProvider is an optional interface of Provider which writes entries in a batch,
+// e.g. by one syscall. The async logger drains queued entries and writes them by WriteBatch.
+type BatchProvider interface {
+	WriteBatch(entries []Entry) error
+}
+
+// WriteBatch writes entries to provider p, BatchProvider is preferred, otherwise
+// entries are written one by one
+func WriteBatch(p Provider, entries []Entry) error {
+	if bp, ok := p.(BatchProvider); ok {
+		return bp.WriteBatch(entries)
+	}
+	var err error
+	for _, e := range entries {
+		if err1 := WriteEntry(p, e); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+// Reopener is an optional interface of Provider and Logger which reopens
+// underlying files, e.g. after the files moved by logrotate
+type Reopener interface {
+	Reopen() error
+}
+
+// Reopen reopens provider p if it's a Reopener
+func Reopen(p Provider) error {
+	if r, ok := p.(Reopener); ok {
+		return r.Reopen()
+	}
+	return nil
+}
+
 // Handler handle the logging entry
 type Handler interface {
 	Handle(entry Entry) error
 }
 
+// HandlerFunc is a function which implements Handler interface
+type HandlerFunc func(entry Entry) error
+
+// Handle calls f(entry)
+func (f HandlerFunc) Handle(entry Entry) error { return f(entry) }
+
+// ProviderSwapper is a logger whose provider can be replaced while running
+type ProviderSwapper interface {
+	// SetProvider replaces the provider with p, entries queued before are written
+	// to the old provider, and then the old provider is closed.
+	SetProvider(p Provider) error
+}
+
 // HookableLogger is a logger which can hook handlers
 type HookableLogger interface {
 	Logger
 	Hook(Handler)
 }
 
-// Stack gets the call stack
-func Stack(calldepth int) []byte {
-	var (
-		e             = make([]byte, 1<<16) // 64k
-		nbytes        = runtime.Stack(e, false)
-		ignorelinenum = 2*calldepth + 1
-		count         = 0
-		startIndex    = 0
-	)
-	for i := range e {
-		if e[i] == '\n' {
-			count++
-			if count == ignorelinenum {
-				startIndex = i + 1
-			}
-		}
-	}
-	return e[startIndex:nbytes]
-}
-
 // logger implements interfaces HookableLogger and With
 type logger struct {
-	level    Level
-	provider Provider
-	noHeader int32
+	level        Level
+	provider     Provider
+	headerFormat int32 // HeaderFormat
 
 	entryListLocker sync.Mutex
 	entryList       *entry
 
 	running    int32
 	writeQueue chan *entry
+	batch      []Entry // reused by writeBatch of the writing goroutine
+	counters   *counters
 	quitNotify chan struct{}
 
 	async       bool
 	writeLocker sync.Mutex // used if async==false
 
-	// hooked handlers
-	handlers []Handler
+	// []*hook, replaced while adding or removing hooks
+	hookList   atomic.Value
+	hooksMu    sync.Mutex
+	lastHookID HookID
+
+	// *flightRecorder, nil if flight recorder mode disabled
+	recorder atomic.Value
+	// *sampler, nil if sampling disabled
+	sampling atomic.Value
+	// []Redactor
+	redactors atomic.Value
+	// FatalPolicy
+	fatalPolicy atomic.Value
+	// StackOpts
+	stackOpts atomic.Value
+	// func() time.Time, nil if time.Now used
+	clock atomic.Value
+	// func(int) (string, int), nil if runtime.Caller used
+	callerFunc atomic.Value
+	// map[string]Level, levels of modules
+	moduleLevels   atomic.Value
+	moduleLevelsMu sync.Mutex
 }
 
 // New creates async logger with provider
@@ -130,9 +256,18 @@ func newLogger(provider Provider, async bool) *withLogger {
 		entryList:  new(entry),
 		writeQueue: make(chan *entry, 8192),
 		quitNotify: make(chan struct{}),
+		counters:   new(counters),
 		async:      async,
-		handlers:   []Handler{},
 	}
+	l.hookList.Store([]*hook(nil))
+	l.recorder.Store((*flightRecorder)(nil))
+	l.sampling.Store((*sampler)(nil))
+	l.redactors.Store([]Redactor(nil))
+	l.fatalPolicy.Store(FatalPolicy{})
+	l.SetStackOpts(StackOpts{})
+	l.moduleLevels.Store(map[string]Level(nil))
+	l.clock.Store((func() time.Time)(nil))
+	l.callerFunc.Store((func(int) (string, int))(nil))
 	return &withLogger{l}
 }
 
@@ -143,7 +278,14 @@ type withLogger struct {
 // LogWith implements With interface
 func (l *withLogger) LogWith(level Level, calldepth int, data []byte, format string, args ...interface{}) {
 	if l.GetLevel() >= level {
-		l.output(level, calldepth, data, format, args...)
+		l.output(level, calldepth, &Context{Data: data}, format, args...)
+	}
+}
+
+// LogContext implements ContextWith interface
+func (l *withLogger) LogContext(level Level, calldepth int, ctx Context, format string, args ...interface{}) {
+	if l.GetModuleLevel(ctx.Module) >= level {
+		l.output(level, calldepth, &ctx, format, args...)
 	}
 }
 
@@ -152,41 +294,132 @@ func (l *logger) Run() {
 		return
 	}
 	go func() {
+		batch := make([]*entry, 0, maxBatchSize)
 		for e := range l.writeQueue {
+			// drains queued entries until a command found
+			batch = append(batch[:0], e)
+			for len(batch) < maxBatchSize && !e.quit && e.call == nil {
+				select {
+				case e = <-l.writeQueue:
+					batch = append(batch, e)
+					continue
+				default:
+				}
+				break
+			}
+			if !e.quit && e.call == nil {
+				l.writeBatch(batch)
+				continue
+			}
+			l.writeBatch(batch[:len(batch)-1])
 			if e.quit {
 				break
 			}
-			l.writeBuffer(e)
+			e.value = e.call()
+			close(e.done)
 		}
 		atomic.StoreInt32(&l.running, 0)
 		l.quitNotify <- struct{}{}
 	}()
 }
 
+// maxBatchSize is the max number of entries written by a WriteBatch
+const maxBatchSize = 256
+
+// writeBatch writes entries by WriteBatch if the provider is a BatchProvider
+func (l *logger) writeBatch(batch []*entry) {
+	bp, ok := l.provider.(BatchProvider)
+	if !ok || len(batch) < 2 || l.flightRecorder() != nil {
+		for _, e := range batch {
+			l.writeBuffer(e)
+		}
+		return
+	}
+	entries := l.batch[:0]
+	for _, e := range batch {
+		entries = append(entries, e)
+	}
+	err := bp.WriteBatch(entries)
+	for i, e := range batch {
+		entries[i] = nil
+		l.counters.written(e, err)
+		err = nil // the error of the batch is counted once
+		l.handle(e)
+		if e.done != nil {
+			close(e.done)
+		}
+		l.putBuffer(e)
+	}
+	l.batch = entries[:0]
+}
+
 func (l *logger) writeBuffer(e *entry) {
-	l.provider.Write(e.level, e.headerLength, e.Bytes())
-	if len(l.handlers) > 0 {
-		for _, h := range l.handlers {
-			h.Handle(e)
+	if r := l.flightRecorder(); r != nil {
+		if e.level.MoreVerboseThan(INFO) {
+			if old := r.push(e); old != nil {
+				atomic.AddUint64(&l.counters.droppedRecorder, 1)
+				l.putBuffer(old)
+			}
+			return
+		}
+		if !e.level.MoreVerboseThan(ERROR) {
+			for _, re := range r.pop(e) {
+				l.write(re)
+				l.putBuffer(re)
+			}
 		}
 	}
-	if e.level == FATAL {
-		l.provider.Close()
-		os.Exit(1)
+	l.write(e)
+	if e.done != nil {
+		close(e.done)
 	}
 	l.putBuffer(e)
 }
 
-func (l *logger) Hook(h Handler) {
-	l.handlers = append(l.handlers, h)
+func (l *logger) write(e *entry) {
+	l.counters.written(e, WriteEntry(l.provider, e))
+	l.handle(e)
+}
+
+// SetProvider implements ProviderSwapper interface
+func (l *logger) SetProvider(p Provider) error {
+	return l.call(func() error {
+		old := l.provider
+		l.provider = p
+		return old.Close()
+	})
+}
+
+// Reopen implements Reopener interface
+func (l *logger) Reopen() error {
+	return l.call(func() error { return Reopen(l.provider) })
+}
+
+// call calls fn after all queued entries written, fn can access the provider exclusively
+func (l *logger) call(fn func() error) error {
+	if l.async && atomic.LoadInt32(&l.running) != 0 {
+		e := &entry{call: fn, done: make(chan struct{})}
+		l.writeQueue <- e
+		<-e.done
+		err, _ := e.value.(error)
+		return err
+	}
+	l.writeLocker.Lock()
+	defer l.writeLocker.Unlock()
+	return fn()
 }
 
 func (l *logger) Quit() {
+	if s := l.sampler(); s != nil {
+		l.outputSummaries(s.flush(l.now()))
+	}
 	if !l.async || atomic.LoadInt32(&l.running) == 0 {
+		l.stopHooks()
 		return
 	}
 	l.writeQueue <- &entry{quit: true}
 	<-l.quitNotify
+	l.stopHooks()
 	l.provider.Close()
 }
 
@@ -213,7 +446,7 @@ func (l *logger) putBuffer(e *entry) {
 	l.entryListLocker.Unlock()
 }
 
-// [L yyyy/MM/dd hh:mm:ss.uuu file:line]
+// [L yyyy/MM/dd hh:mm:ss.uuu file:line] or [L hh:mm:ss.uuu file:line] for HeaderShort
 func (l *logger) formatHeader(now time.Time, level Level, file string, line int) *entry {
 	if line < 0 {
 		line = 0
@@ -224,25 +457,33 @@ func (l *logger) formatHeader(now time.Time, level Level, file string, line int)
 		hour, minute, second = now.Clock()
 		millisecond          = now.Nanosecond() / 1000000
 	)
+	e.time = now
 	e.timestamp = now.Unix()
+	e.file = file
+	e.line = line
 	e.tmp[0] = '['
 	e.tmp[1] = level.String()[0]
 	e.tmp[2] = ' '
-	fourDigits(e, 3, year)
-	e.tmp[7] = '/'
-	twoDigits(e, 8, int(month))
-	e.tmp[10] = '/'
-	twoDigits(e, 11, day)
-	e.tmp[13] = ' '
-	twoDigits(e, 14, hour)
-	e.tmp[16] = ':'
-	twoDigits(e, 17, minute)
-	e.tmp[19] = ':'
-	twoDigits(e, 20, second)
-	e.tmp[22] = '.'
-	threeDigits(e, 23, millisecond)
-	e.tmp[26] = ' '
-	e.Write(e.tmp[:27])
+	if HeaderFormat(atomic.LoadInt32(&l.headerFormat)) == HeaderShort {
+		e.Write(e.tmp[:3])
+	} else {
+		fourDigits(e, 3, year)
+		e.tmp[7] = '/'
+		twoDigits(e, 8, int(month))
+		e.tmp[10] = '/'
+		twoDigits(e, 11, day)
+		e.tmp[13] = ' '
+		e.Write(e.tmp[:14])
+	}
+	twoDigits(e, 0, hour)
+	e.tmp[2] = ':'
+	twoDigits(e, 3, minute)
+	e.tmp[5] = ':'
+	twoDigits(e, 6, second)
+	e.tmp[8] = '.'
+	threeDigits(e, 9, millisecond)
+	e.tmp[12] = ' '
+	e.Write(e.tmp[:13])
 	e.WriteString(file)
 	e.tmp[0] = ':'
 	n := someDigits(e, 1, line)
@@ -253,35 +494,58 @@ func (l *logger) formatHeader(now time.Time, level Level, file string, line int)
 	return e
 }
 
-func (l *logger) header(level Level, calldepth int) *entry {
-	now := time.Now()
-	if atomic.LoadInt32(&l.noHeader) != 0 {
+// newEntry creates an entry with header
+func (l *logger) newEntry(now time.Time, level Level, file string, line int) *entry {
+	if HeaderFormat(atomic.LoadInt32(&l.headerFormat)) == HeaderNone {
 		e := l.getBuffer()
+		e.time = now
 		e.timestamp = now.Unix()
 		return e
 	}
-	_, file, line, ok := runtime.Caller(calldepth)
-	if !ok {
-		file = "???"
-		line = 0
-	} else {
-		slash := strings.LastIndex(file, "/")
-		if slash >= 0 {
-			file = file[slash+1:]
-		}
+	slash := strings.LastIndex(file, "/")
+	if slash >= 0 {
+		file = file[slash+1:]
+	}
+	return l.formatHeader(now, level, file, line)
+}
+
+func (l *logger) header(level Level, calldepth int) *entry {
+	now := l.now()
+	if HeaderFormat(atomic.LoadInt32(&l.headerFormat)) == HeaderNone {
+		return l.newEntry(now, level, "", 0)
 	}
-	return l.formatHeader(time.Now(), level, file, line)
+	file, line := l.caller(calldepth)
+	return l.newEntry(now, level, file, line)
 }
 
-func (l *logger) output(level Level, calldepth int, data []byte, format string, args ...interface{}) {
+func (l *logger) output(level Level, calldepth int, ctx *Context, format string, args ...interface{}) {
+	if s := l.sampler(); s != nil && level != FATAL && level != PANIC {
+		var pc [1]uintptr
+		runtime.Callers(calldepth+3, pc[:])
+		allowed, summaries := s.allow(l.now(), level, pc[0], format)
+		l.outputSummaries(summaries)
+		if !allowed {
+			atomic.AddUint64(&l.counters.droppedSampling, 1)
+			return
+		}
+	}
 	e := l.header(level, calldepth+3)
 	e.headerLength = e.Len()
-	if len(data) > 0 {
-		e.bodyBegin = e.Len()
-		e.Write(data)
-		e.bodyEnd = e.Len()
-		if len(format) > 0 {
-			e.WriteString(" | ")
+	if ctx != nil {
+		e.value = snapshot(ctx.Value)
+		if ctx.Module != "" {
+			e.module = ctx.Module
+			e.WriteByte('[')
+			e.WriteString(ctx.Module)
+			e.WriteString("] ")
+		}
+		if len(ctx.Data) > 0 {
+			e.bodyBegin = e.Len()
+			e.Write(ctx.Data)
+			e.bodyEnd = e.Len()
+			if len(format) > 0 {
+				e.WriteString(" | ")
+			}
 		}
 	}
 	e.descBegin = e.Len()
@@ -291,19 +555,36 @@ func (l *logger) output(level Level, calldepth int, data []byte, format string,
 		fmt.Fprintf(e, format, args...)
 	}
 	e.descEnd = e.Len()
+	l.redact(e)
 	if e.Len() > 0 && e.Bytes()[e.Len()-1] != '\n' {
 		e.WriteByte('\n')
 	}
-	if level == FATAL {
-		stackBuf := Stack(4)
-		e.WriteString("========= BEGIN STACK TRACE =========\n")
-		e.Write(stackBuf)
-		e.WriteString("========== END STACK TRACE ==========\n")
-	}
+	l.writeStack(e, level, calldepth+2)
 	if e.Len() == 0 {
 		return
 	}
 	e.level = level
+	if level != FATAL && level != PANIC {
+		l.dispatch(e)
+		return
+	}
+	// waits until the entry written, then terminates
+	var (
+		msg  = string(e.Desc())
+		done = make(chan struct{})
+	)
+	e.done = done
+	l.dispatch(e)
+	select {
+	case <-done:
+	case <-time.After(maxWaitTimeForImportantLevel):
+	}
+	terminate(l.fatalPolicy.Load().(FatalPolicy), level, msg, l.flush)
+}
+
+// dispatch writes the entry e directly or puts it to the write queue, it's dropped
+// if the queue is full for too long
+func (l *logger) dispatch(e *entry) {
 	maxWaitTime := maxWaitTimeForImportantLevel
 	if e.level.MoreVerboseThan(INFO) {
 		maxWaitTime = maxWaitTimeForVerboseLevel
@@ -312,6 +593,7 @@ func (l *logger) output(level Level, calldepth int, data []byte, format string,
 		select {
 		case l.writeQueue <- e:
 		case <-time.After(maxWaitTime):
+			atomic.AddUint64(&l.counters.droppedQueue, 1)
 		}
 	} else {
 		l.writeLocker.Lock()
@@ -320,7 +602,7 @@ func (l *logger) output(level Level, calldepth int, data []byte, format string,
 	}
 }
 
-func (l *logger) NoHeader()         { atomic.StoreInt32(&l.noHeader, 1) }
+func (l *logger) NoHeader()         { l.SetHeaderFormat(HeaderNone) }
 func (l *logger) GetLevel() Level   { return Level(atomic.LoadInt32((*int32)(&l.level))) }
 func (l *logger) SetLevel(lv Level) { atomic.StoreInt32((*int32)(&l.level), int32(lv)) }
 
@@ -356,5 +638,8 @@ func (l *logger) Error(calldepth int, format string, args ...interface{}) {
 
 func (l *logger) Fatal(calldepth int, format string, args ...interface{}) {
 	l.output(FATAL, calldepth, nil, format, args...)
-	select {}
+}
+
+func (l *logger) Panic(calldepth int, format string, args ...interface{}) {
+	l.output(PANIC, calldepth, nil, format, args...)
 }
@@ -2,6 +2,9 @@ package logger
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -58,6 +61,11 @@ func TestLevel(t *testing.T) {
 	assert.Equal(t, ERROR, MustParseLevel("1"))
 	assert.Equal(t, FATAL, MustParseLevel("0"))
 
+	assert.Equal(t, "PANIC", PANIC.String())
+	assert.Equal(t, PANIC, MustParseLevel("panic"))
+	assert.Equal(t, PANIC, MustParseLevel("P"))
+	assert.Equal(t, PANIC, MustParseLevel("-1"))
+
 	assert.Panics(t, func() { MustParseLevel("xTrace") })
 	assert.Panics(t, func() { MustParseLevel("xDebug") })
 	assert.Panics(t, func() { MustParseLevel("xInfo") })
@@ -186,3 +194,127 @@ func TestForm2JSON(t *testing.T) {
 		}
 	}
 }
+
+func TestSetProvider(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		var (
+			p1 = newMockProvider()
+			p2 = newMockProvider()
+			l  = newLogger(p1, async)
+		)
+		l.SetLevel(INFO)
+		l.Run()
+		const n = 1000
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < n; i++ {
+				l.Info(0, "x")
+			}
+			close(done)
+		}()
+		time.Sleep(time.Millisecond)
+		assert.Nil(t, l.SetProvider(p2))
+		<-done
+		l.Quit()
+		assert.Equal(t, n*2, p1.data.Len()+p2.data.Len())
+	}
+}
+
+// batchProvider records sizes of batches
+type batchProvider struct {
+	mockProvider
+	batches []int
+	err     error // returned by WriteBatch
+}
+
+func (p *batchProvider) WriteBatch(entries []Entry) error {
+	p.batches = append(p.batches, len(entries))
+	for _, e := range entries {
+		d := Detailed(e)
+		p.Write(d.Level(), d.HeaderLength(), d.Bytes())
+	}
+	return p.err
+}
+
+func TestWriteBatch(t *testing.T) {
+	p := &batchProvider{mockProvider: *newMockProvider()}
+	l := newLogger(p, true)
+	l.SetLevel(INFO)
+	l.NoHeader()
+	// entries are queued before the writing goroutine started
+	atomic.StoreInt32(&l.running, 1)
+	const n = 1000
+	var expected bytes.Buffer
+	for i := 0; i < n; i++ {
+		l.Info(0, "%d", i)
+		fmt.Fprintf(&expected, "%d\n", i)
+	}
+	atomic.StoreInt32(&l.running, 0)
+	l.Run()
+	l.Quit()
+	assert.Equal(t, expected.String(), p.data.String())
+	total := 0
+	for _, size := range p.batches {
+		assert.True(t, size <= maxBatchSize)
+		total += size
+	}
+	assert.Equal(t, n, total)
+	assert.True(t, len(p.batches) < n/2, "batches: %v", p.batches)
+}
+
+func TestWriteBatchError(t *testing.T) {
+	p := &batchProvider{mockProvider: *newMockProvider(), err: errors.New("broken")}
+	l := newLogger(p, true)
+	l.SetLevel(INFO)
+	atomic.StoreInt32(&l.running, 1)
+	for i := 0; i < 10; i++ {
+		l.Info(0, "%d", i)
+	}
+	atomic.StoreInt32(&l.running, 0)
+	l.Run()
+	l.Quit()
+	stats := l.Stats()
+	assert.Equal(t, uint64(10), stats.Entries[INFO])
+	assert.True(t, len(p.batches) < 10, "batches: %v", p.batches)
+	assert.Equal(t, uint64(len(p.batches)), stats.Errors)
+}
+
+// plainEntry implements Entry without details
+type plainEntry struct{}
+
+func (plainEntry) Level() Level     { return WARN }
+func (plainEntry) Timestamp() int64 { return 100 }
+func (plainEntry) Body() []byte     { return nil }
+func (plainEntry) Desc() []byte     { return []byte("plain") }
+func (e plainEntry) Clone() Entry   { return e }
+
+func TestDetailed(t *testing.T) {
+	d := Detailed(plainEntry{})
+	assert.Equal(t, WARN, d.Level())
+	assert.Equal(t, time.Unix(100, 0), d.Time())
+	assert.Equal(t, []byte("plain"), d.Bytes())
+	assert.Equal(t, 0, d.HeaderLength())
+	assert.Nil(t, d.Value())
+	assert.Nil(t, d.Stack())
+
+	p := newMockProvider()
+	assert.Nil(t, WriteEntry(p, plainEntry{}))
+	assert.Equal(t, "plain", p.data.String())
+
+	l := newLogger(p, false)
+	l.SetLevel(INFO)
+	h := new(detailedHandler)
+	l.Hook(h)
+	l.Info(0, "detailed")
+	assert.True(t, h.detailed)
+}
+
+// detailedHandler records whether the entry implements DetailedEntry
+type detailedHandler struct {
+	detailed bool
+}
+
+func (h *detailedHandler) Handle(e Entry) error {
+	_, h.detailed = e.(DetailedEntry)
+	return nil
+}
@@ -0,0 +1,55 @@
+package logger
+
+import "strings"
+
+// ModuleLeveler is a logger which supports levels per module.
+//
+// Modules are hierarchical: the level of module "db/sql" falls back to the level
+// of module "db" if not set, and then to the level of the logger.
+// Both '/' and '.' are recognized as separators.
+type ModuleLeveler interface {
+	// GetModuleLevel gets level of the module
+	GetModuleLevel(module string) Level
+	// SetModuleLevel sets level of the module
+	SetModuleLevel(module string, level Level)
+	// ResetModuleLevels removes levels of all modules
+	ResetModuleLevels()
+}
+
+// GetModuleLevel implements ModuleLeveler interface
+func (l *logger) GetModuleLevel(module string) Level {
+	if module != "" {
+		levels := l.moduleLevels.Load().(map[string]Level)
+		for len(levels) > 0 {
+			if lv, ok := levels[module]; ok {
+				return lv
+			}
+			i := strings.LastIndexAny(module, "/.")
+			if i < 0 {
+				break
+			}
+			module = module[:i]
+		}
+	}
+	return l.GetLevel()
+}
+
+// SetModuleLevel implements ModuleLeveler interface
+func (l *logger) SetModuleLevel(module string, level Level) {
+	l.moduleLevelsMu.Lock()
+	defer l.moduleLevelsMu.Unlock()
+	old := l.moduleLevels.Load().(map[string]Level)
+	levels := make(map[string]Level, len(old)+1)
+	for k, v := range old {
+		levels[k] = v
+	}
+	levels[module] = level
+	l.moduleLevels.Store(levels)
+}
+
+// ResetModuleLevels implements ModuleLeveler interface
+func (l *logger) ResetModuleLevels() {
+	l.moduleLevelsMu.Lock()
+	defer l.moduleLevelsMu.Unlock()
+	l.moduleLevels.Store(map[string]Level(nil))
+}
@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleLevel(t *testing.T) {
+	p := newMockProvider()
+	l := newLogger(p, false)
+	l.SetLevel(INFO)
+	l.SetModuleLevel("db", DEBUG)
+	l.SetModuleLevel("db/sql", ERROR)
+
+	assert.Equal(t, INFO, l.GetModuleLevel(""))
+	assert.Equal(t, INFO, l.GetModuleLevel("cache"))
+	assert.Equal(t, DEBUG, l.GetModuleLevel("db"))
+	assert.Equal(t, DEBUG, l.GetModuleLevel("db.redis"))
+	assert.Equal(t, ERROR, l.GetModuleLevel("db/sql/tx"))
+
+	l.LogContext(DEBUG, 0, Context{Module: "db"}, "debug")
+	l.LogContext(DEBUG, 0, Context{Module: "cache"}, "hidden")
+	l.LogContext(WARN, 0, Context{Module: "db/sql"}, "hidden")
+	l.LogContext(INFO, 0, Context{Module: "cache", Data: []byte("k=v")}, "info")
+	assert.Equal(t, "[db] debug\n[cache] k=v | info\n", p.data.String())
+
+	l.ResetModuleLevels()
+	assert.Equal(t, INFO, l.GetModuleLevel("db"))
+}
+
+func TestHeaderFormat(t *testing.T) {
+	var entries []Entry
+	p := entryWriterFunc(func(e Entry) error {
+		entries = append(entries, e.Clone())
+		return nil
+	})
+	l := newLogger(p, false)
+	l.SetLevel(INFO)
+
+	l.Info(0, "default")
+	l.SetHeaderFormat(HeaderShort)
+	l.Info(0, "short")
+	l.SetHeaderFormat(HeaderNone)
+	l.Info(0, "none")
+
+	assert.Equal(t, 3, len(entries))
+	header := func(e Entry) string {
+		d := Detailed(e)
+		return string(d.Bytes()[:d.HeaderLength()])
+	}
+	assert.Regexp(t, regexp.MustCompile(`^\[I \d{4}/\d\d/\d\d \d\d:\d\d:\d\d\.\d{3} module_test\.go:\d+\] $`), header(entries[0]))
+	assert.Regexp(t, regexp.MustCompile(`^\[I \d\d:\d\d:\d\d\.\d{3} module_test\.go:\d+\] $`), header(entries[1]))
+	assert.Equal(t, "", header(entries[2]))
+	file, line := Detailed(entries[1]).Caller()
+	assert.Equal(t, "module_test.go", file)
+	assert.True(t, line > 0)
+	assert.False(t, Detailed(entries[2]).Time().IsZero())
+	assert.True(t, bytes.Equal([]byte("none\n"), Detailed(entries[2]).Bytes()))
+
+	for _, s := range []string{"", "default", "short", "none"} {
+		f, err := ParseHeaderFormat(s)
+		assert.Nil(t, err)
+		if s != "" {
+			assert.Equal(t, s, f.String())
+		}
+	}
+	_, err := ParseHeaderFormat("long")
+	assert.Equal(t, ErrUnrecognizedHeaderFormat, err)
+}
+
+// entryWriterFunc is a provider which receives entries by function
+type entryWriterFunc func(e Entry) error
+
+func (f entryWriterFunc) WriteEntry(e Entry) error { return f(e) }
+func (f entryWriterFunc) Write(level Level, headerLength int, data []byte) error {
+	panic("EntryWriter preferred")
+}
+func (f entryWriterFunc) Close() error { return nil }
@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"reflect"
+	"sync"
+)
+
+// FlightRecorderOpts represents options of flight recorder mode.
+//
+// In flight recorder mode, TRACE and DEBUG entries are kept in memory instead of
+// being written. When an ERROR or FATAL entry occurs, the recorded entries are
+// flushed just before it. Note that the logger's level still decides whether
+// verbose entries are created, so it should be set to DEBUG or TRACE.
+// If Key is set but the error entry has no such field, all recorded entries are flushed.
+type FlightRecorderOpts struct {
+	Size int    // max number of recorded entries(default: 256)
+	Key  string // if not empty, only entries which have the same value of field Key as the error entry are flushed
+}
+
+// FlightRecorder is a logger which supports flight recorder mode
+type FlightRecorder interface {
+	// SetFlightRecorder enables flight recorder mode, or disables it if opts is nil
+	SetFlightRecorder(opts *FlightRecorderOpts)
+}
+
+// flightRecorder is a ring buffer of recorded entries
+type flightRecorder struct {
+	mu      sync.Mutex
+	key     string
+	entries []*entry
+	head    int // index of the oldest entry
+	size    int
+}
+
+func newFlightRecorder(opts FlightRecorderOpts) *flightRecorder {
+	if opts.Size <= 0 {
+		opts.Size = 256
+	}
+	return &flightRecorder{
+		key:     opts.Key,
+		entries: make([]*entry, opts.Size),
+	}
+}
+
+// push records e and returns the evicted entry if the buffer is full
+func (r *flightRecorder) push(e *entry) (evicted *entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size == len(r.entries) {
+		evicted = r.entries[r.head]
+		r.entries[r.head] = e
+		r.head = (r.head + 1) % len(r.entries)
+		return
+	}
+	r.entries[(r.head+r.size)%len(r.entries)] = e
+	r.size++
+	return
+}
+
+// pop removes and returns recorded entries which should be flushed before the error entry e
+func (r *flightRecorder) pop(e *entry) []*entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size == 0 {
+		return nil
+	}
+	var (
+		want    interface{}
+		matched = false
+	)
+	if r.key != "" {
+		want, matched = LookupField(e.value, r.key)
+	}
+	var (
+		popped = make([]*entry, 0, r.size)
+		kept   = 0
+	)
+	for i := 0; i < r.size; i++ {
+		re := r.entries[(r.head+i)%len(r.entries)]
+		if matched {
+			if v, ok := LookupField(re.value, r.key); !ok || !reflect.DeepEqual(v, want) {
+				r.entries[(r.head+kept)%len(r.entries)] = re
+				kept++
+				continue
+			}
+		}
+		popped = append(popped, re)
+	}
+	for i := kept; i < r.size; i++ {
+		r.entries[(r.head+i)%len(r.entries)] = nil
+	}
+	r.size = kept
+	return popped
+}
+
+// SetFlightRecorder implements FlightRecorder interface
+func (l *logger) SetFlightRecorder(opts *FlightRecorderOpts) {
+	if opts == nil {
+		l.recorder.Store((*flightRecorder)(nil))
+	} else {
+		l.recorder.Store(newFlightRecorder(*opts))
+	}
+}
+
+func (l *logger) flightRecorder() *flightRecorder {
+	return l.recorder.Load().(*flightRecorder)
+}
@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	p := newMockProvider()
+	l := newLogger(p, false)
+	l.SetLevel(TRACE)
+	l.SetFlightRecorder(&FlightRecorderOpts{Size: 2})
+
+	l.Debug(0, "debug 1")
+	l.Trace(0, "trace 2")
+	l.Debug(0, "debug 3")
+	l.Info(0, "info")
+	assert.Equal(t, "info\n", p.data.String())
+
+	l.Error(0, "error")
+	assert.Equal(t, "info\ntrace 2\ndebug 3\nerror\n", p.data.String())
+
+	p.data.Reset()
+	l.Error(0, "error again")
+	assert.Equal(t, "error again\n", p.data.String())
+
+	p.data.Reset()
+	l.SetFlightRecorder(nil)
+	l.Debug(0, "debug")
+	assert.Equal(t, "debug\n", p.data.String())
+}
+
+func TestFlightRecorderKey(t *testing.T) {
+	p := newMockProvider()
+	l := newLogger(p, false)
+	l.SetLevel(TRACE)
+	l.SetFlightRecorder(&FlightRecorderOpts{Key: "rid"})
+
+	ctx := func(rid int) Context {
+		return Context{Value: map[string]interface{}{"rid": rid}}
+	}
+	l.LogContext(DEBUG, 0, ctx(1), "debug 1")
+	l.LogContext(DEBUG, 0, ctx(2), "debug 2")
+	l.LogContext(DEBUG, 0, ctx(1), "debug 3")
+	l.LogContext(ERROR, 0, ctx(1), "error 1")
+	assert.Equal(t, "debug 1\ndebug 3\nerror 1\n", p.data.String())
+
+	p.data.Reset()
+	l.Error(0, "error without key")
+	assert.Equal(t, "debug 2\nerror without key\n", p.data.String())
+}
+
+func TestLookupField(t *testing.T) {
+	type T struct {
+		A int `json:"a"`
+		B string
+		c bool
+	}
+	for i, tc := range []struct {
+		value interface{}
+		key   string
+		want  interface{}
+		ok    bool
+	}{
+		{nil, "a", nil, false},
+		{map[string]interface{}{"a": 1}, "a", 1, true},
+		{map[string]interface{}{"a": 1}, "b", nil, false},
+		{map[int]int{1: 1}, "1", nil, false},
+		{T{A: 1, B: "b"}, "a", 1, true},
+		{&T{A: 1, B: "b"}, "B", "b", true},
+		{T{c: true}, "c", nil, false},
+		{[]interface{}{1, map[string]string{"a": "x"}}, "a", "x", true},
+		{"a", "a", nil, false},
+	} {
+		got, ok := LookupField(tc.value, tc.key)
+		assert.Equal(t, tc.ok, ok, "%dth", i)
+		assert.Equal(t, tc.want, got, "%dth", i)
+	}
+}
+
+func TestContextSnapshot(t *testing.T) {
+	p := newMockProvider()
+	l := newLogger(p, true)
+	l.SetLevel(TRACE)
+	l.SetFlightRecorder(&FlightRecorderOpts{Key: "rid"})
+	var values []interface{}
+	l.Hook(HandlerFunc(func(e Entry) error {
+		values = append(values, Detailed(e).Value())
+		return nil
+	}))
+	l.Run()
+
+	// the caller modifies the value while entries are written by another goroutine
+	m := map[string]interface{}{"rid": 1, "d": time.Second}
+	for i := 0; i < 100; i++ {
+		m["rid"] = i % 2
+		l.LogContext(DEBUG, 0, Context{Value: m}, "debug %d", i)
+	}
+	m["rid"] = 1
+	l.LogContext(ERROR, 0, Context{Value: m}, "error")
+	m["rid"] = 2
+	l.Quit()
+
+	assert.Equal(t, 51, len(values))
+	assert.Equal(t, map[string]interface{}{"rid": 1, "d": time.Second}, values[len(values)-1])
+}
+
+func TestSnapshot(t *testing.T) {
+	type request struct {
+		Headers map[string]string
+		Tags    []string
+		Latency time.Duration
+	}
+	req := &request{Headers: map[string]string{"a": "1"}, Tags: []string{"x"}, Latency: time.Second}
+	m := map[string]interface{}{"req": req, "ids": []interface{}{1, map[string]int{"n": 1}}}
+	m["self"] = m
+
+	c := snapshot(m).(map[string]interface{})
+	req.Headers["a"] = "2"
+	req.Tags[0] = "y"
+	m["ids"].([]interface{})[1].(map[string]int)["n"] = 2
+	m["new"] = true
+
+	creq := c["req"].(*request)
+	assert.Equal(t, &request{Headers: map[string]string{"a": "1"}, Tags: []string{"x"}, Latency: time.Second}, creq)
+	assert.Equal(t, []interface{}{1, map[string]int{"n": 1}}, c["ids"])
+	_, ok := c["new"]
+	assert.False(t, ok)
+	assert.Nil(t, snapshot(nil))
+	assert.Equal(t, "text", snapshot("text"))
+}
@@ -0,0 +1,197 @@
+package logger
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// Mask is the replacement of redacted data
+const Mask = "******"
+
+// Redactor redacts sensitive data in text.
+// Redact returns the redacted text, it may modify text in place.
+type Redactor interface {
+	Redact(text []byte) []byte
+}
+
+// RedactorFunc is a function which implements Redactor interface
+type RedactorFunc func(text []byte) []byte
+
+// Redact implements Redactor interface
+func (f RedactorFunc) Redact(text []byte) []byte { return f(text) }
+
+// Redactable is a logger which supports redaction
+type Redactable interface {
+	// SetRedactors sets redactors applied to context data and message of every entry before
+	// written to provider and handlers
+	SetRedactors(redactors ...Redactor)
+}
+
+type regexRedactor struct {
+	re *regexp.Regexp
+}
+
+// NewRegexRedactor creates a Redactor which replaces all matches of re with Mask.
+// If re has submatches, only the first submatch of every match is replaced.
+func NewRegexRedactor(re *regexp.Regexp) Redactor {
+	return regexRedactor{re: re}
+}
+
+func (r regexRedactor) Redact(text []byte) []byte {
+	if r.re.NumSubexp() == 0 {
+		return r.re.ReplaceAllLiteral(text, []byte(Mask))
+	}
+	matches := r.re.FindAllSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	redacted := make([]byte, 0, len(text))
+	last := 0
+	for _, m := range matches {
+		if m[2] < 0 {
+			continue
+		}
+		redacted = append(redacted, text[last:m[2]]...)
+		redacted = append(redacted, Mask...)
+		last = m[3]
+	}
+	return append(redacted, text[last:]...)
+}
+
+// Built-in redactors
+var (
+	// JWTRedactor redacts JSON web tokens
+	JWTRedactor = NewRegexRedactor(regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`))
+	// AWSKeyRedactor redacts AWS access key ids
+	AWSKeyRedactor = NewRegexRedactor(regexp.MustCompile(`\b(?:AKIA|ASIA|AIDA|AROA)[0-9A-Z]{16}\b`))
+	// AWSSecretRedactor redacts AWS secret access keys assigned to aws_secret_access_key
+	AWSSecretRedactor = NewRegexRedactor(regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`))
+	// EmailRedactor redacts email addresses
+	EmailRedactor = NewRegexRedactor(regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`))
+	// PANRedactor redacts primary account numbers(card numbers) which pass the Luhn check
+	PANRedactor Redactor = RedactorFunc(redactPAN)
+)
+
+// DefaultRedactors is a list of all built-in redactors
+var DefaultRedactors = []Redactor{
+	JWTRedactor,
+	AWSKeyRedactor,
+	AWSSecretRedactor,
+	PANRedactor,
+	EmailRedactor,
+}
+
+// redactPAN masks runs of 13 to 19 digits, optionally separated by single spaces
+// or dashes, which pass the Luhn check
+func redactPAN(text []byte) []byte {
+	var (
+		redacted []byte
+		last     = 0
+	)
+	for i := 0; i < len(text); {
+		if !isDigit(text[i]) || (i > 0 && isDigit(text[i-1])) {
+			i++
+			continue
+		}
+		var (
+			end    = i
+			digits = 0
+			j      = i
+		)
+		for j < len(text) && digits < 19 {
+			if isDigit(text[j]) {
+				digits++
+				j++
+				end = j
+				continue
+			}
+			if (text[j] == ' ' || text[j] == '-') && j+1 < len(text) && isDigit(text[j+1]) {
+				j++
+				continue
+			}
+			break
+		}
+		if digits >= 13 && (end == len(text) || !isDigit(text[end])) && luhn(text[i:end]) {
+			redacted = append(redacted, text[last:i]...)
+			redacted = append(redacted, Mask...)
+			last = end
+			i = end
+			continue
+		}
+		i++
+	}
+	if redacted == nil {
+		return text
+	}
+	return append(redacted, text[last:]...)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func luhn(s []byte) bool {
+	var (
+		sum    = 0
+		double = false
+	)
+	for i := len(s) - 1; i >= 0; i-- {
+		if !isDigit(s[i]) {
+			continue
+		}
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// SetRedactors implements Redactable interface
+func (l *logger) SetRedactors(redactors ...Redactor) {
+	l.redactors.Store(append([]Redactor(nil), redactors...))
+}
+
+// redact applies redactors to context data and message of entry e
+func (l *logger) redact(e *entry) {
+	redactors := l.redactors.Load().([]Redactor)
+	if len(redactors) == 0 {
+		return
+	}
+	var (
+		b     = e.Bytes()
+		start = e.descBegin
+		body  []byte
+		sep   []byte
+	)
+	if e.bodyEnd > e.bodyBegin {
+		start = e.bodyBegin
+		body = redactText(redactors, append([]byte(nil), b[e.bodyBegin:e.bodyEnd]...))
+		if !bytes.Equal(body, b[e.bodyBegin:e.bodyEnd]) {
+			// the original value contains sensitive data
+			e.value = nil
+		}
+		sep = append([]byte(nil), b[e.bodyEnd:e.descBegin]...)
+	}
+	desc := redactText(redactors, append([]byte(nil), b[e.descBegin:e.descEnd]...))
+	e.Truncate(start)
+	if body != nil {
+		e.bodyBegin = e.Len()
+		e.Write(body)
+		e.bodyEnd = e.Len()
+		e.Write(sep)
+	}
+	e.descBegin = e.Len()
+	e.Write(desc)
+	e.descEnd = e.Len()
+}
+
+func redactText(redactors []Redactor, text []byte) []byte {
+	for _, r := range redactors {
+		text = r.Redact(text)
+	}
+	return text
+}
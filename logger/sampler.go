@@ -0,0 +1,247 @@
+package logger
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// SampleKey represents the key by which entries are sampled
+type SampleKey int
+
+const (
+	SampleByCaller SampleKey = iota // samples entries by call site
+	SampleByFormat                  // samples entries by message template(the format string)
+)
+
+// SamplingOpts represents options of log sampling.
+//
+// All policies are optional and are applied together, an entry is written
+// only if it passes all enabled policies. FATAL entries are never sampled.
+// Suppressed entries are reported per site by a summary entry at the end of
+// every interval in which something has been suppressed.
+type SamplingOpts struct {
+	By         SampleKey     // key of sampling(default: SampleByCaller)
+	Interval   time.Duration // sampling interval(default: 1s)
+	First      int           // writes the first N entries of every key per interval, 0 means no limit
+	Thereafter int           // writes every Mth entry after the first N entries, 0 means drops all of them
+	Rate       float64       // token bucket rate(entries per second) of every key, 0 means no token bucket
+	Burst      int           // token bucket size(default: ceil(Rate))
+	Budgets    map[Level]int // max number of entries of the level per interval across all keys
+}
+
+// Sampler is a logger which supports log sampling
+type Sampler interface {
+	// SetSampling enables log sampling, or disables it if opts is nil
+	SetSampling(opts *SamplingOpts)
+}
+
+type sampleKey struct {
+	pc     uintptr
+	format string
+}
+
+type sampleSite struct {
+	windowStart time.Time
+	count       int
+	tokens      float64
+	last        time.Time // last time of refilling tokens
+	seen        time.Time // last time of any entry
+
+	// for summary
+	pc         uintptr
+	level      Level
+	suppressed int
+	since      time.Time
+}
+
+type samplingSummary struct {
+	pc         uintptr
+	level      Level
+	suppressed int
+	elapsed    time.Duration
+}
+
+type sampler struct {
+	mu    sync.Mutex
+	opts  SamplingOpts
+	sites map[sampleKey]*sampleSite
+	idle  time.Duration // sites idle for it are evicted, their states equal to new sites
+
+	windowStart time.Time
+	budgets     [NumLevel]int // remaining budgets of current window, -1 means no limit
+}
+
+func newSampler(opts SamplingOpts) *sampler {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Rate > 0 && opts.Burst <= 0 {
+		opts.Burst = int(math.Ceil(opts.Rate))
+	}
+	s := &sampler{
+		opts:  opts,
+		sites: make(map[sampleKey]*sampleSite),
+		idle:  opts.Interval,
+	}
+	if opts.Rate > 0 {
+		// the token bucket of an idle site must be full
+		if d := time.Duration(float64(opts.Burst) / opts.Rate * float64(time.Second)); d > s.idle {
+			s.idle = d
+		}
+	}
+	s.resetBudgets()
+	return s
+}
+
+func (s *sampler) resetBudgets() {
+	for lv := range s.budgets {
+		s.budgets[lv] = -1
+		if n, ok := s.opts.Budgets[Level(lv)]; ok {
+			s.budgets[lv] = n
+		}
+	}
+}
+
+// allow reports whether the entry should be written, and returns summaries
+// of suppressed entries whose interval has ended.
+func (s *sampler) allow(now time.Time, level Level, pc uintptr, format string) (bool, []samplingSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var summaries []samplingSummary
+	if now.Sub(s.windowStart) >= s.opts.Interval {
+		s.windowStart = now
+		s.resetBudgets()
+		summaries = s.summarize(now, false)
+		s.evict(now)
+	}
+
+	key := sampleKey{format: format}
+	if s.opts.By == SampleByCaller {
+		key = sampleKey{pc: pc}
+	}
+	site, ok := s.sites[key]
+	if !ok {
+		site = &sampleSite{
+			windowStart: now,
+			tokens:      float64(s.opts.Burst),
+			last:        now,
+		}
+		s.sites[key] = site
+	}
+	if now.Sub(site.windowStart) >= s.opts.Interval {
+		site.windowStart = now
+		site.count = 0
+	}
+	site.count++
+	site.seen = now
+
+	allowed := true
+	if s.opts.First > 0 && site.count > s.opts.First {
+		if s.opts.Thereafter <= 0 || (site.count-s.opts.First)%s.opts.Thereafter != 0 {
+			allowed = false
+		}
+	}
+	if allowed && s.opts.Rate > 0 {
+		site.tokens += now.Sub(site.last).Seconds() * s.opts.Rate
+		if site.tokens > float64(s.opts.Burst) {
+			site.tokens = float64(s.opts.Burst)
+		}
+		site.last = now
+		if site.tokens >= 1 {
+			site.tokens--
+		} else {
+			allowed = false
+		}
+	}
+	if allowed && level >= 0 && level < NumLevel && s.budgets[level] >= 0 {
+		if s.budgets[level] > 0 {
+			s.budgets[level]--
+		} else {
+			allowed = false
+		}
+	}
+	if !allowed {
+		if site.suppressed == 0 {
+			site.since = now
+		}
+		site.suppressed++
+		site.pc = pc
+		site.level = level
+	}
+	return allowed, summaries
+}
+
+// summarize returns summaries of suppressed entries and resets the counters.
+// Only sites whose first suppression is at least one interval ago are summarized unless all is true.
+func (s *sampler) summarize(now time.Time, all bool) []samplingSummary {
+	var summaries []samplingSummary
+	for _, site := range s.sites {
+		if site.suppressed == 0 {
+			continue
+		}
+		if !all && now.Sub(site.since) < s.opts.Interval {
+			continue
+		}
+		summaries = append(summaries, samplingSummary{
+			pc:         site.pc,
+			level:      site.level,
+			suppressed: site.suppressed,
+			elapsed:    now.Sub(site.since),
+		})
+		site.suppressed = 0
+	}
+	return summaries
+}
+
+// evict removes idle sites which have nothing to summarize, so sites of
+// messages logged once don't stay forever
+func (s *sampler) evict(now time.Time) {
+	for key, site := range s.sites {
+		if site.suppressed == 0 && now.Sub(site.seen) >= s.idle {
+			delete(s.sites, key)
+		}
+	}
+}
+
+func (s *sampler) flush(now time.Time) []samplingSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.summarize(now, true)
+}
+
+// SetSampling implements Sampler interface
+func (l *logger) SetSampling(opts *SamplingOpts) {
+	old := l.sampler()
+	if opts == nil {
+		l.sampling.Store((*sampler)(nil))
+	} else {
+		l.sampling.Store(newSampler(*opts))
+	}
+	if old != nil {
+		l.outputSummaries(old.flush(l.now()))
+	}
+}
+
+func (l *logger) sampler() *sampler {
+	return l.sampling.Load().(*sampler)
+}
+
+func (l *logger) outputSummaries(summaries []samplingSummary) {
+	for _, s := range summaries {
+		frame, _ := runtime.CallersFrames([]uintptr{s.pc}).Next()
+		e := l.newEntry(l.now(), s.level, frame.File, frame.Line)
+		e.headerLength = e.Len()
+		e.descBegin = e.Len()
+		fmt.Fprintf(e, "suppressed %d entries at %s:%d by sampling in last %v",
+			s.suppressed, filepath.Base(frame.File), frame.Line, s.elapsed.Round(time.Millisecond))
+		e.descEnd = e.Len()
+		e.WriteByte('\n')
+		e.level = s.level
+		l.dispatch(e)
+	}
+}
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamplerFirstThereafter(t *testing.T) {
+	s := newSampler(SamplingOpts{First: 2, Thereafter: 3})
+	now := time.Now()
+	var got []bool
+	for i := 0; i < 8; i++ {
+		ok, _ := s.allow(now, INFO, 1, "")
+		got = append(got, ok)
+	}
+	assert.Equal(t, []bool{true, true, false, false, true, false, false, true}, got)
+
+	// another site
+	ok, _ := s.allow(now, INFO, 2, "")
+	assert.True(t, ok)
+
+	// next interval
+	ok, summaries := s.allow(now.Add(time.Second), INFO, 1, "")
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(summaries))
+	assert.Equal(t, 4, summaries[0].suppressed)
+	assert.Equal(t, uintptr(1), summaries[0].pc)
+}
+
+func TestSamplerByFormat(t *testing.T) {
+	s := newSampler(SamplingOpts{By: SampleByFormat, First: 1})
+	now := time.Now()
+	ok, _ := s.allow(now, INFO, 1, "a")
+	assert.True(t, ok)
+	ok, _ = s.allow(now, INFO, 2, "a")
+	assert.False(t, ok)
+	ok, _ = s.allow(now, INFO, 2, "b")
+	assert.True(t, ok)
+}
+
+func TestSamplerTokenBucket(t *testing.T) {
+	s := newSampler(SamplingOpts{Rate: 2})
+	now := time.Now()
+	for i, want := range []bool{true, true, false} {
+		ok, _ := s.allow(now, INFO, 1, "")
+		assert.Equal(t, want, ok, "%dth", i)
+	}
+	ok, _ := s.allow(now.Add(500*time.Millisecond), INFO, 1, "")
+	assert.True(t, ok)
+	ok, _ = s.allow(now.Add(500*time.Millisecond), INFO, 1, "")
+	assert.False(t, ok)
+}
+
+func TestSamplerBudgets(t *testing.T) {
+	s := newSampler(SamplingOpts{Budgets: map[Level]int{DEBUG: 1}})
+	now := time.Now()
+	ok, _ := s.allow(now, DEBUG, 1, "")
+	assert.True(t, ok)
+	ok, _ = s.allow(now, DEBUG, 2, "")
+	assert.False(t, ok)
+	ok, _ = s.allow(now, INFO, 2, "")
+	assert.True(t, ok)
+	ok, _ = s.allow(now.Add(time.Second), DEBUG, 2, "")
+	assert.True(t, ok)
+}
+
+func TestSamplerEvictIdleSites(t *testing.T) {
+	s := newSampler(SamplingOpts{First: 1})
+	now := time.Now()
+	for pc := uintptr(1); pc <= 100; pc++ {
+		s.allow(now, INFO, pc, "")
+	}
+	assert.Equal(t, 100, len(s.sites))
+
+	// site 1 has a suppressed entry to summarize, site 101 is still active
+	now = now.Add(600 * time.Millisecond)
+	ok, _ := s.allow(now, INFO, 1, "")
+	assert.False(t, ok)
+	s.allow(now, INFO, 101, "")
+	ok, summaries := s.allow(now.Add(900*time.Millisecond), INFO, 102, "")
+	assert.True(t, ok)
+	assert.Equal(t, 0, len(summaries))
+	assert.Equal(t, 3, len(s.sites))
+	for _, pc := range []uintptr{1, 101, 102} {
+		_, ok = s.sites[sampleKey{pc: pc}]
+		assert.True(t, ok, "site %d", pc)
+	}
+}
+
+func TestLoggerSamplingSummary(t *testing.T) {
+	p := newMockProvider()
+	l := newLogger(p, false)
+	l.SetLevel(INFO)
+	l.SetSampling(&SamplingOpts{First: 1, Interval: time.Hour})
+	for i := 0; i < 3; i++ {
+		l.Info(0, "hello")
+	}
+	l.SetSampling(nil)
+	lines := strings.Split(strings.TrimSpace(p.data.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, "hello", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "suppressed 2 entries at sampler_test.go:"), lines[1])
+}
@@ -0,0 +1,184 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const maxStackDepth = 64
+
+// Frame represents a frame of call stack
+type Frame struct {
+	Function string `json:"func"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Frames represents a call stack
+type Frames []Frame
+
+// Text renders frames like runtime.Stack:
+//
+//	github.com/user/pkg.function
+//		/path/to/file.go:10
+func (frames Frames) Text() []byte {
+	var buf bytes.Buffer
+	for _, f := range frames {
+		buf.WriteString(f.Function)
+		buf.WriteString("\n\t")
+		buf.WriteString(f.File)
+		buf.WriteByte(':')
+		buf.WriteString(strconv.Itoa(f.Line))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// JSON renders frames as a JSON array
+func (frames Frames) JSON() []byte {
+	if frames == nil {
+		return []byte("[]")
+	}
+	b, _ := json.Marshal([]Frame(frames))
+	return b
+}
+
+// StackFilter reports whether the frame should be dropped
+type StackFilter func(f Frame) bool
+
+// SkipRuntimeFrames drops frames of package runtime
+func SkipRuntimeFrames(f Frame) bool {
+	return strings.HasPrefix(f.Function, "runtime.")
+}
+
+const logPackage = "github.com/mkideal/log"
+
+// SkipLogFrames drops frames of this log module except test files
+func SkipLogFrames(f Frame) bool {
+	if strings.HasSuffix(f.File, "_test.go") {
+		return false
+	}
+	return strings.HasPrefix(f.Function, logPackage+".") || strings.HasPrefix(f.Function, logPackage+"/")
+}
+
+// DefaultStackFilters are filters used if no filters specified
+var DefaultStackFilters = []StackFilter{SkipRuntimeFrames, SkipLogFrames}
+
+// Callers returns at most depth frames of the call stack, skip is the number of
+// frames to skip, 0 identifying the caller of Callers.
+func Callers(skip, depth int, filters ...StackFilter) Frames {
+	if depth <= 0 {
+		depth = maxStackDepth
+	}
+	pcs := make([]uintptr, depth+16)
+	n := runtime.Callers(skip+2, pcs)
+	return CallersFrames(pcs[:n], depth, filters...)
+}
+
+// CallersFrames returns at most depth frames of program counters pcs returned by runtime.Callers
+func CallersFrames(pcs []uintptr, depth int, filters ...StackFilter) Frames {
+	if len(pcs) == 0 {
+		return nil
+	}
+	var (
+		frames Frames
+		iter   = runtime.CallersFrames(pcs)
+	)
+	for depth <= 0 || len(frames) < depth {
+		rf, more := iter.Next()
+		if rf.Function != "" {
+			f := Frame{Function: rf.Function, File: rf.File, Line: rf.Line}
+			if !dropFrame(f, filters) {
+				frames = append(frames, f)
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+func dropFrame(f Frame, filters []StackFilter) bool {
+	for _, filter := range filters {
+		if filter(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stack gets the call stack in text, calldepth is the number of frames to skip,
+// 0 identifying the caller of Stack.
+func Stack(calldepth int) []byte {
+	return Callers(calldepth+1, maxStackDepth, DefaultStackFilters...).Text()
+}
+
+// AllStacks gets call stacks of all goroutines
+func AllStacks() []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		if len(buf) >= 1<<26 {
+			return buf
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// StackOpts represents options of stacks attached to entries.
+// Stacks are always attached to FATAL and PANIC entries.
+type StackOpts struct {
+	OnError       bool          // attaches stacks to ERROR entries
+	AllGoroutines bool          // dumps stacks of all goroutines on FATAL
+	Depth         int           // max number of frames(default: 64)
+	JSON          bool          // renders stacks as JSON array instead of text
+	Filters       []StackFilter // frame filters(default: DefaultStackFilters)
+}
+
+// StackConfigurable is a logger whose stack options are configurable
+type StackConfigurable interface {
+	// SetStackOpts sets stack options
+	SetStackOpts(opts StackOpts)
+}
+
+// SetStackOpts implements StackConfigurable interface
+func (l *logger) SetStackOpts(opts StackOpts) {
+	if opts.Depth <= 0 {
+		opts.Depth = maxStackDepth
+	}
+	if opts.Filters == nil {
+		opts.Filters = DefaultStackFilters
+	}
+	l.stackOpts.Store(opts)
+}
+
+// writeStack attaches stack to entry e if needed, skip is the number of frames
+// to skip, 0 identifying the caller of writeStack.
+func (l *logger) writeStack(e *entry, level Level, skip int) {
+	opts := l.stackOpts.Load().(StackOpts)
+	if level != FATAL && level != PANIC && (level != ERROR || !opts.OnError) {
+		return
+	}
+	e.stack = Callers(skip+1, opts.Depth, opts.Filters...)
+	if opts.JSON {
+		e.WriteString("stack=")
+		e.Write(e.stack.JSON())
+		e.WriteByte('\n')
+	} else {
+		e.WriteString("========= BEGIN STACK TRACE =========\n")
+		e.Write(e.stack.Text())
+		e.WriteString("========== END STACK TRACE ==========\n")
+	}
+	if level == FATAL && opts.AllGoroutines {
+		e.WriteString("========= BEGIN GOROUTINES DUMP =========\n")
+		e.Write(AllStacks())
+		e.WriteString("========== END GOROUTINES DUMP ==========\n")
+	}
+}
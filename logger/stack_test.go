@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallers(t *testing.T) {
+	frames := Callers(0, 1)
+	assert.Equal(t, 1, len(frames))
+	assert.Equal(t, logPackage+"/logger.TestCallers", frames[0].Function)
+	assert.True(t, strings.HasSuffix(frames[0].File, "stack_test.go"))
+
+	for _, f := range Callers(0, 0, DefaultStackFilters...) {
+		assert.False(t, strings.HasPrefix(f.Function, "runtime."), f.Function)
+	}
+	assert.True(t, strings.HasPrefix(string(Stack(0)), logPackage+"/logger.TestCallers\n\t"))
+}
+
+func TestStackFilters(t *testing.T) {
+	assert.True(t, SkipRuntimeFrames(Frame{Function: "runtime.goexit"}))
+	assert.False(t, SkipRuntimeFrames(Frame{Function: "main.main"}))
+	assert.True(t, SkipLogFrames(Frame{Function: logPackage + "/logger.(*logger).output", File: "logger.go"}))
+	assert.True(t, SkipLogFrames(Frame{Function: logPackage + ".Info", File: "log.go"}))
+	assert.False(t, SkipLogFrames(Frame{Function: logPackage + ".TestInfo", File: "log_test.go"}))
+	assert.False(t, SkipLogFrames(Frame{Function: "github.com/mkideal/logx.Info", File: "log.go"}))
+}
+
+type stackHandler struct {
+	stack Frames
+}
+
+func (h *stackHandler) Handle(e Entry) error {
+	h.stack = Detailed(e).Stack()
+	return nil
+}
+
+func TestStackOnError(t *testing.T) {
+	p := newMockProvider()
+	l := newLogger(p, false)
+	l.SetLevel(INFO)
+	h := new(stackHandler)
+	l.Hook(h)
+
+	l.Error(0, "error")
+	assert.Equal(t, "error\n", p.data.String())
+	assert.Nil(t, h.stack)
+
+	p.data.Reset()
+	l.SetStackOpts(StackOpts{OnError: true, Depth: 1, JSON: true})
+	l.Error(0, "error")
+	assert.Equal(t, 1, len(h.stack))
+	assert.Equal(t, logPackage+"/logger.TestStackOnError", h.stack[0].Function)
+	lines := strings.Split(p.data.String(), "\n")
+	assert.Equal(t, "error", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "stack="))
+	var frames Frames
+	assert.Nil(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "stack=")), &frames))
+	assert.Equal(t, h.stack, frames)
+}
+
+func TestAllGoroutinesOnFatal(t *testing.T) {
+	p := newMockProvider()
+	l := newLogger(p, false)
+	var r ExitRecorder
+	l.SetFatalPolicy(r.Policy())
+	l.SetStackOpts(StackOpts{AllGoroutines: true})
+	l.Fatal(0, "fatal")
+	s := p.data.String()
+	assert.True(t, strings.HasPrefix(s, "fatal\n========= BEGIN STACK TRACE =========\n"+logPackage+"/logger.TestAllGoroutinesOnFatal\n"), s)
+	assert.True(t, strings.Contains(s, "========= BEGIN GOROUTINES DUMP =========\ngoroutine "), s)
+}
@@ -0,0 +1,70 @@
+package logger
+
+import "sync/atomic"
+
+// Stats represents counters of a logger
+type Stats struct {
+	Entries       map[Level]uint64  // number of entries written to the provider by level
+	Bytes         uint64            // number of bytes written to the provider
+	Errors        uint64            // number of errors returned by the provider
+	HookErrors    uint64            // number of errors returned or panics raised by hooks
+	Dropped       map[string]uint64 // number of entries dropped by reason: sampling, flight_recorder, hook_queue or queue_timeout
+	QueueDepth    int               // number of entries in the queue of async logger
+	QueueCapacity int               // capacity of the queue of async logger
+}
+
+// StatsReporter is a logger which reports its counters
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// reasons of dropped entries
+const (
+	dropSampling       = "sampling"
+	dropFlightRecorder = "flight_recorder"
+	dropHookQueue      = "hook_queue"
+	dropQueueTimeout   = "queue_timeout"
+)
+
+// counters of a logger, it's allocated separately to keep 64-bit alignment
+type counters struct {
+	entries         [NumLevel + 1]uint64 // indexed by level+1
+	bytes           uint64
+	errors          uint64
+	droppedSampling uint64
+	droppedRecorder uint64
+	droppedHook     uint64
+	droppedQueue    uint64
+	hookErrors      uint64
+}
+
+// written records result of writing e
+func (c *counters) written(e *entry, err error) {
+	atomic.AddUint64(&c.entries[e.Level()+1], 1)
+	atomic.AddUint64(&c.bytes, uint64(len(e.Bytes())))
+	if err != nil {
+		atomic.AddUint64(&c.errors, 1)
+	}
+}
+
+// Stats implements StatsReporter interface
+func (l *logger) Stats() Stats {
+	stats := Stats{
+		Entries:    make(map[Level]uint64, len(l.counters.entries)),
+		Bytes:      atomic.LoadUint64(&l.counters.bytes),
+		Errors:     atomic.LoadUint64(&l.counters.errors),
+		HookErrors: atomic.LoadUint64(&l.counters.hookErrors),
+		Dropped: map[string]uint64{
+			dropSampling:       atomic.LoadUint64(&l.counters.droppedSampling),
+			dropFlightRecorder: atomic.LoadUint64(&l.counters.droppedRecorder),
+			dropHookQueue:      atomic.LoadUint64(&l.counters.droppedHook),
+			dropQueueTimeout:   atomic.LoadUint64(&l.counters.droppedQueue),
+		},
+		QueueDepth:    len(l.writeQueue),
+		QueueCapacity: cap(l.writeQueue),
+	}
+	for i := range l.counters.entries {
+		stats.Entries[Level(i-1)] = atomic.LoadUint64(&l.counters.entries[i])
+	}
+	return stats
+}
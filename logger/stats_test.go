@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	p := newMockProvider()
+	l := newLogger(p, false)
+	l.SetLevel(DEBUG)
+	l.NoHeader()
+	l.Info(0, "hello")
+	l.Warn(0, "world")
+	l.SetSampling(&SamplingOpts{First: 1, Interval: time.Hour})
+	for i := 0; i < 3; i++ {
+		l.Error(0, "sampled")
+	}
+	l.SetFlightRecorder(&FlightRecorderOpts{Size: 1})
+	l.Debug(0, "evicted")
+	l.Debug(0, "buffered")
+
+	stats := l.Stats()
+	assert.Equal(t, uint64(1), stats.Entries[INFO])
+	assert.Equal(t, uint64(1), stats.Entries[WARN])
+	assert.Equal(t, uint64(1), stats.Entries[ERROR])
+	assert.Equal(t, uint64(0), stats.Entries[DEBUG])
+	assert.Equal(t, uint64(p.data.Len()), stats.Bytes)
+	assert.Equal(t, uint64(0), stats.Errors)
+	assert.Equal(t, uint64(2), stats.Dropped[dropSampling])
+	assert.Equal(t, uint64(1), stats.Dropped[dropFlightRecorder])
+	assert.Equal(t, 0, stats.QueueDepth)
+}
+
+func TestStatsQueueTimeout(t *testing.T) {
+	p := newMockProvider()
+	l := newLogger(p, true)
+	l.SetLevel(TRACE)
+	l.NoHeader()
+	// the writing goroutine isn't started, so the queue isn't drained
+	atomic.StoreInt32(&l.running, 1)
+	for i := 0; i < cap(l.writeQueue); i++ {
+		l.Debug(0, "queued")
+	}
+	l.Debug(0, "dropped")
+	assert.Equal(t, uint64(1), l.Stats().Dropped[dropQueueTimeout])
+
+	atomic.StoreInt32(&l.running, 0)
+	l.Run()
+	l.Quit()
+	assert.Equal(t, uint64(cap(l.writeQueue)), l.Stats().Entries[DEBUG])
+	assert.NotContains(t, p.data.String(), "dropped")
+}
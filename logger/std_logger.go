@@ -4,48 +4,59 @@ import (
 	"bytes"
 	"fmt"
 	stdlog "log"
-	"os"
 	"sync/atomic"
 )
 
-type stdLogger Level
+type stdLogger struct {
+	level       Level
+	fatalPolicy atomic.Value // FatalPolicy
+	redactors   atomic.Value // []Redactor
+}
 
 // NewStdLogger creates std logger
 func NewStdLogger() Logger {
-	l := new(stdLogger)
-	*l = stdLogger(INFO)
+	l := &stdLogger{level: INFO}
+	l.fatalPolicy.Store(FatalPolicy{})
+	l.redactors.Store([]Redactor(nil))
 	return l
 }
 
 func (l *stdLogger) Run()                 {}
 func (l *stdLogger) Quit()                {}
 func (l *stdLogger) NoHeader()            { stdlog.SetPrefix("") }
-func (l *stdLogger) GetLevel() Level      { return Level(atomic.LoadInt32((*int32)(l))) }
-func (l *stdLogger) SetLevel(level Level) { atomic.StoreInt32((*int32)(l), int32(level)) }
+func (l *stdLogger) GetLevel() Level      { return Level(atomic.LoadInt32((*int32)(&l.level))) }
+func (l *stdLogger) SetLevel(level Level) { atomic.StoreInt32((*int32)(&l.level), int32(level)) }
+
+// SetFatalPolicy implements FatalConfigurable interface
+func (l *stdLogger) SetFatalPolicy(policy FatalPolicy) { l.fatalPolicy.Store(policy) }
+
+// SetRedactors implements Redactable interface, redactors are applied to the whole
+// message which includes context data
+func (l *stdLogger) SetRedactors(redactors ...Redactor) {
+	l.redactors.Store(append([]Redactor(nil), redactors...))
+}
 
 func (l *stdLogger) output(calldepth int, level Level, format string, args ...interface{}) {
-	if level != FATAL {
-		if len(args) == 0 {
-			stdlog.Output(calldepth+3, fmt.Sprint(format))
-		} else {
-			stdlog.Output(calldepth+3, fmt.Sprintf(format, args...))
-		}
+	msg := format
+	if len(args) > 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
+	if redactors := l.redactors.Load().([]Redactor); len(redactors) > 0 {
+		msg = string(redactText(redactors, []byte(msg)))
+	}
+	if level != FATAL && level != PANIC {
+		stdlog.Output(calldepth+3, msg)
 	} else {
-		buf := new(bytes.Buffer)
-		if len(args) == 0 {
-			fmt.Fprint(buf, format)
-		} else {
-			fmt.Fprintf(buf, format, args...)
-		}
+		buf := bytes.NewBufferString(msg)
 		if buf.Len() == 0 || buf.Bytes()[buf.Len()-1] != '\n' {
 			buf.WriteByte('\n')
 		}
-		stackBuf := Stack(4)
+		stackBuf := Stack(calldepth + 2)
 		buf.WriteString("========= BEGIN STACK TRACE =========\n")
 		buf.Write(stackBuf)
 		buf.WriteString("========== END STACK TRACE ==========\n")
-		l.output(calldepth, TRACE, buf.String())
-		os.Exit(1)
+		stdlog.Output(calldepth+3, buf.String())
+		terminate(l.fatalPolicy.Load().(FatalPolicy), level, msg, nil)
 	}
 }
 
@@ -82,3 +93,7 @@ func (l *stdLogger) Error(calldepth int, format string, args ...interface{}) {
 func (l *stdLogger) Fatal(calldepth int, format string, args ...interface{}) {
 	l.output(calldepth, FATAL, format, args...)
 }
+
+func (l *stdLogger) Panic(calldepth int, format string, args ...interface{}) {
+	l.output(calldepth, PANIC, format, args...)
+}
@@ -0,0 +1,327 @@
+// Package logtest implements a logger which records entries for tests, e.g.
+//
+//	func TestServer(t *testing.T) {
+//		l := logtest.New(t, nil)
+//		s := NewServer(l)
+//		s.Handle(request)
+//		l.AssertLogged(t, logger.INFO, "request done", logtest.F("status", 200))
+//		l.AssertNoErrors(t)
+//	}
+//
+// Output of the logger is written by t.Log, so it's attributed to the test and
+// shown only if the test failed or -v used.
+package logtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mkideal/log"
+	"github.com/mkideal/log/logger"
+)
+
+// Entry is a recorded entry
+type Entry struct {
+	Level   logger.Level
+	Time    time.Time
+	File    string // base name of the file, empty if the header format is HeaderNone
+	Line    int
+	Module  string
+	Message string                 // message without header, module and data
+	Fields  map[string]interface{} // context fields decoded as JSON, nil if no fields
+	Text    string                 // the formatted line without trailing newline
+}
+
+// Field returns the field and whether it's present
+func (e Entry) Field(key string) (interface{}, bool) {
+	v, ok := e.Fields[key]
+	return v, ok
+}
+
+// Field represents a field matched by AssertLogged
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F creates a Field
+func F(key string, value interface{}) Field { return Field{Key: key, Value: value} }
+
+// Recorder is a provider which records entries
+type Recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+	t       testing.TB // nil if output not written to t.Log
+	done    bool       // the test finished
+}
+
+// NewRecorder creates a Recorder, entries are written to t.Log if t isn't nil
+func NewRecorder(t testing.TB) *Recorder {
+	r := &Recorder{t: t}
+	if t != nil {
+		t.Cleanup(func() {
+			r.mu.Lock()
+			r.done = true
+			r.mu.Unlock()
+		})
+	}
+	return r
+}
+
+// Write implements logger.Provider interface
+func (r *Recorder) Write(level logger.Level, headerLength int, data []byte) error {
+	text := strings.TrimRight(string(data), "\n")
+	if headerLength > len(text) {
+		headerLength = len(text)
+	}
+	r.record(Entry{Level: level, Message: text[headerLength:], Text: text})
+	return nil
+}
+
+// WriteEntry implements logger.EntryWriter interface
+func (r *Recorder) WriteEntry(entry logger.Entry) error {
+	e := logger.Detailed(entry)
+	file, line := e.Caller()
+	if file != "" {
+		file = filepath.Base(file)
+	}
+	r.record(Entry{
+		Level:   e.Level(),
+		Time:    e.Time(),
+		File:    file,
+		Line:    line,
+		Module:  e.Module(),
+		Message: string(e.Desc()),
+		Fields:  fields(e),
+		Text:    strings.TrimRight(string(e.Bytes()), "\n"),
+	})
+	return nil
+}
+
+// fields decodes context fields of e as JSON, so numbers are float64
+func fields(e logger.DetailedEntry) map[string]interface{} {
+	var data []byte
+	if v := e.Value(); v != nil {
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil
+		}
+	} else if data = e.Body(); len(data) == 0 || data[0] != '{' {
+		return nil
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return m
+}
+
+func (r *Recorder) record(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, e)
+	if r.t != nil && !r.done {
+		r.t.Log(e.Text)
+	}
+}
+
+// Close implements logger.Provider interface
+func (r *Recorder) Close() error { return nil }
+
+// Entries returns recorded entries
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Reset removes recorded entries
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
+
+// String returns recorded lines, it's used to compare with golden files
+func (r *Recorder) String() string {
+	var buf bytes.Buffer
+	for _, e := range r.Entries() {
+		buf.WriteString(e.Text)
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// Find returns entries of level whose message contains substr and which have all fields
+func (r *Recorder) Find(level logger.Level, substr string, fields ...Field) []Entry {
+	var found []Entry
+	for _, e := range r.Entries() {
+		if e.Level == level && strings.Contains(e.Message, substr) && hasFields(e, fields) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func hasFields(e Entry, fields []Field) bool {
+	for _, f := range fields {
+		v, ok := e.Field(f.Key)
+		if !ok || !reflect.DeepEqual(v, normalize(f.Value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize converts v like a decoded JSON value, e.g. numbers to float64
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var x interface{}
+	if err := json.Unmarshal(data, &x); err != nil {
+		return v
+	}
+	return x
+}
+
+// AssertLogged asserts that an entry of level whose message contains substr and
+// which has all fields is recorded
+func (r *Recorder) AssertLogged(t testing.TB, level logger.Level, substr string, fields ...Field) bool {
+	t.Helper()
+	if len(r.Find(level, substr, fields...)) > 0 {
+		return true
+	}
+	t.Errorf("no %s entry contains %q%s, recorded:\n%s", level, substr, formatFields(fields), r.String())
+	return false
+}
+
+// AssertNotLogged asserts that no entry of level whose message contains substr and
+// which has all fields is recorded
+func (r *Recorder) AssertNotLogged(t testing.TB, level logger.Level, substr string, fields ...Field) bool {
+	t.Helper()
+	found := r.Find(level, substr, fields...)
+	if len(found) == 0 {
+		return true
+	}
+	t.Errorf("unexpected %s entry contains %q%s: %s", level, substr, formatFields(fields), found[0].Text)
+	return false
+}
+
+// AssertNoErrors asserts that no ERROR, FATAL or PANIC entry is recorded
+func (r *Recorder) AssertNoErrors(t testing.TB) bool {
+	t.Helper()
+	var errs []string
+	for _, e := range r.Entries() {
+		if !e.Level.MoreVerboseThan(logger.ERROR) {
+			errs = append(errs, e.Text)
+		}
+	}
+	if len(errs) == 0 {
+		return true
+	}
+	t.Errorf("%d error entries recorded:\n%s", len(errs), strings.Join(errs, "\n"))
+	return false
+}
+
+func formatFields(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	s := make([]string, len(fields))
+	for i, f := range fields {
+		s[i] = fmt.Sprintf("%s=%v", f.Key, f.Value)
+	}
+	return " with " + strings.Join(s, ", ")
+}
+
+// Opts represents options of Logger
+type Opts struct {
+	Level  string                                      // level of the logger(default: trace)
+	Header logger.HeaderFormat                         // header format of the logger(default: HeaderDefault)
+	Clock  func() time.Time                            // clock of the logger(default: time.Now), see FixedClock and StepClock
+	Caller func(calldepth int) (file string, line int) // caller of entries(default: runtime.Caller), see FixedCaller
+	Quiet  bool                                        // doesn't write output to t.Log
+}
+
+// Logger is a sync logger which records entries. Optional interfaces of the logger,
+// e.g. logger.ContextWith, are implemented by the embedded HookableLogger.
+type Logger struct {
+	logger.HookableLogger
+	*Recorder
+}
+
+// New creates a Logger for the test, default options are used if opts is nil.
+// The logger is quit while the test finished.
+func New(t testing.TB, opts *Opts) *Logger {
+	if opts == nil {
+		opts = new(Opts)
+	}
+	level := logger.TRACE
+	if opts.Level != "" {
+		var ok bool
+		if level, ok = logger.ParseLevel(opts.Level); !ok {
+			t.Fatalf("logtest: %v: %q", logger.ErrUnrecognizedLogLevel, opts.Level)
+		}
+	}
+	var r *Recorder
+	if opts.Quiet {
+		r = NewRecorder(nil)
+	} else {
+		r = NewRecorder(t)
+	}
+	l := &Logger{HookableLogger: logger.NewSync(r), Recorder: r}
+	l.SetLevel(level)
+	if hf, ok := l.HookableLogger.(logger.HeaderFormatter); ok {
+		hf.SetHeaderFormat(opts.Header)
+	}
+	if d, ok := l.HookableLogger.(logger.Deterministic); ok {
+		d.SetClock(opts.Clock)
+		d.SetCaller(opts.Caller)
+	}
+	t.Cleanup(l.Quit)
+	return l
+}
+
+// InitGlobal creates a Logger for the test and inits global logger of package log
+// with it, the previous global logger is restored while the test finished
+func InitGlobal(t testing.TB, opts *Opts) *Logger {
+	l := New(t, opts)
+	old := log.SwapLogger(l.HookableLogger)
+	t.Cleanup(func() { log.SwapLogger(old) })
+	return l
+}
+
+// FixedClock returns a clock which always returns t
+func FixedClock(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+// StepClock returns a clock which returns start at the first call and advances
+// step at every call
+func StepClock(start time.Time, step time.Duration) func() time.Time {
+	var (
+		mu   sync.Mutex
+		next = start
+	)
+	return func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now := next
+		next = next.Add(step)
+		return now
+	}
+}
+
+// FixedCaller returns a caller which always returns file and line
+func FixedCaller(file string, line int) func(calldepth int) (string, int) {
+	return func(int) (string, int) { return file, line }
+}
@@ -0,0 +1,136 @@
+package logtest
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log"
+	"github.com/mkideal/log/logger"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// fakeTB records logs and errors instead of failing the test
+type fakeTB struct {
+	testing.TB
+	logs     []string
+	errors   []string
+	cleanups []func()
+}
+
+func (t *fakeTB) Helper()                 {}
+func (t *fakeTB) Log(args ...interface{}) { t.logs = append(t.logs, fmt.Sprint(args...)) }
+func (t *fakeTB) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+func (t *fakeTB) Cleanup(fn func()) { t.cleanups = append(t.cleanups, fn) }
+
+func (t *fakeTB) finish() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func logContext(l *Logger, level logger.Level, module string, fields map[string]interface{}, msg string) {
+	l.HookableLogger.(logger.ContextWith).LogContext(level, 0, logger.Context{Module: module, Value: fields}, msg)
+}
+
+func TestAssertions(t *testing.T) {
+	ft := new(fakeTB)
+	l := New(ft, &Opts{Level: "info", Header: logger.HeaderNone})
+	l.Debug(0, "hidden")
+	logContext(l, logger.INFO, "http", map[string]interface{}{"user": "alice", "latency": 120}, "request done")
+	l.Warn(0, "slow %s", "query")
+
+	assert.True(t, l.AssertLogged(ft, logger.INFO, "request"))
+	assert.True(t, l.AssertLogged(ft, logger.INFO, "", F("user", "alice"), F("latency", 120)))
+	assert.True(t, l.AssertLogged(ft, logger.WARN, "slow query"))
+	assert.True(t, l.AssertNotLogged(ft, logger.DEBUG, "hidden"))
+	assert.True(t, l.AssertNoErrors(ft))
+	assert.Equal(t, 0, len(ft.errors))
+
+	assert.False(t, l.AssertLogged(ft, logger.INFO, "request", F("user", "bob")))
+	assert.False(t, l.AssertLogged(ft, logger.ERROR, "slow"))
+	assert.False(t, l.AssertNotLogged(ft, logger.WARN, "slow"))
+	l.Error(0, "failed")
+	assert.False(t, l.AssertNoErrors(ft))
+	assert.Equal(t, 4, len(ft.errors))
+	assert.True(t, strings.HasPrefix(ft.errors[0], `no INFO entry contains "request" with user=bob, recorded:`), ft.errors[0])
+	assert.Equal(t, "1 error entries recorded:\nfailed", ft.errors[3])
+
+	entries := l.Entries()
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, "http", entries[0].Module)
+	assert.Equal(t, "request done", entries[0].Message)
+	assert.Equal(t, "", entries[0].File)
+	assert.Equal(t, float64(120), entries[0].Fields["latency"])
+
+	// output is written to t.Log until the test finished
+	assert.Equal(t, []string{"[http] request done", "slow query", "failed"}, ft.logs)
+	ft.finish()
+	l.Info(0, "finished")
+	assert.Equal(t, 3, len(ft.logs))
+	assert.Equal(t, 4, len(l.Entries()))
+	l.Reset()
+	assert.Equal(t, "", l.String())
+}
+
+func TestGolden(t *testing.T) {
+	l := New(t, &Opts{
+		Clock:  StepClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), 1500*time.Millisecond),
+		Caller: FixedCaller("main.go", 42),
+	})
+	l.Info(0, "starting")
+	logContext(l, logger.WARN, "db", map[string]interface{}{"retry": 1}, "reconnecting")
+	l.Error(0, "stopped")
+
+	golden := filepath.Join("testdata", "golden.log")
+	if *update {
+		assert.Nil(t, ioutil.WriteFile(golden, []byte(l.String()), 0644))
+	}
+	want, err := ioutil.ReadFile(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, string(want), l.String())
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 6, 5e8, time.UTC), l.Entries()[1].Time)
+}
+
+func TestCaller(t *testing.T) {
+	_, _, line, _ := runtime.Caller(0)
+	var calls int
+	l := New(t, &Opts{
+		Quiet: true,
+		Clock: FixedClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Caller: func(calldepth int) (string, int) {
+			calls++
+			_, file, line, _ := runtime.Caller(calldepth)
+			return file, line
+		},
+	})
+	l.Info(0, "hello")
+	assert.Equal(t, 1, calls)
+	e := l.Entries()[0]
+	assert.Equal(t, "logtest_test.go", e.File)
+	assert.Equal(t, line+11, e.Line)
+	assert.Equal(t, fmt.Sprintf("[I 2020/01/02 03:04:05.000 logtest_test.go:%d] hello", line+11), e.Text)
+}
+
+func TestInitGlobal(t *testing.T) {
+	prev := InitGlobal(t, nil)
+	var l *Logger
+	t.Run("global", func(t *testing.T) {
+		l = InitGlobal(t, &Opts{Caller: FixedCaller("main.go", 1)})
+		log.With(log.M{"id": 7}).Error("failed")
+		l.AssertLogged(t, logger.ERROR, "failed", F("id", 7))
+	})
+	log.Error("after test")
+	assert.Equal(t, 1, len(l.Entries()))
+	prev.AssertLogged(t, logger.ERROR, "after test")
+}
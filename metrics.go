@@ -0,0 +1,51 @@
+package log
+
+import (
+	"net/http"
+
+	"github.com/mkideal/log/logger"
+	"github.com/mkideal/log/metrics"
+)
+
+// Stats returns counters of global logger
+func Stats() (logger.Stats, error) {
+	s, ok := glogger().(logger.StatsReporter)
+	if !ok {
+		return logger.Stats{}, ErrUnsupported
+	}
+	return s.Stats(), nil
+}
+
+// HTTPHandlerMetrics returns a http handler which serves metrics of logging in
+// Prometheus text format, see package metrics
+func HTTPHandlerMetrics() http.Handler {
+	return metrics.Handler()
+}
+
+// statsFunc returns a function which reads a counter from stats of global logger
+func statsFunc(get func(logger.Stats) float64) func() float64 {
+	return func() float64 {
+		s, err := Stats()
+		if err != nil {
+			return 0
+		}
+		return get(s)
+	}
+}
+
+// counters of global logger are exported with label provider=logger or queue=logger
+func init() {
+	const name = "logger"
+	for level := LvPANIC; level < logger.NumLevel; level++ {
+		level := level
+		metrics.Entries.Func(statsFunc(func(s logger.Stats) float64 { return float64(s.Entries[level]) }), level.String(), name)
+	}
+	metrics.WrittenBytes.Func(statsFunc(func(s logger.Stats) float64 { return float64(s.Bytes) }), name)
+	metrics.WriteErrors.Func(statsFunc(func(s logger.Stats) float64 { return float64(s.Errors) }), name)
+	metrics.HookErrors.Func(statsFunc(func(s logger.Stats) float64 { return float64(s.HookErrors) }))
+	for _, reason := range []string{metrics.DropSampling, metrics.DropFlightRecorder, metrics.DropHookQueue, metrics.DropQueueTimeout} {
+		reason := reason
+		metrics.Dropped.Func(statsFunc(func(s logger.Stats) float64 { return float64(s.Dropped[reason]) }), reason)
+	}
+	metrics.QueueDepth.Func(statsFunc(func(s logger.Stats) float64 { return float64(s.QueueDepth) }), name)
+}
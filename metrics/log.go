@@ -0,0 +1,24 @@
+package metrics
+
+// reasons of dropped entries
+const (
+	DropSampling       = "sampling"        // dropped by sampling or rate limiting
+	DropFlightRecorder = "flight_recorder" // verbose entries evicted from flight recorder
+	DropDiskGuard      = "disk_guard"      // dropped while free space is low
+	DropQueueFull      = "queue_full"      // dropped while the queue of an async provider is full
+	DropHookQueue      = "hook_queue"      // dropped while the queue of an async hook is full
+	DropQueueTimeout   = "queue_timeout"   // dropped while the queue of an async logger is full for too long
+)
+
+// metrics of logging in Default registry
+var (
+	Entries      = Default.Counter("log_entries_total", "Number of entries written by providers.", "level", "provider")
+	WrittenBytes = Default.Counter("log_written_bytes_total", "Number of bytes written by providers.", "provider")
+	Dropped      = Default.Counter("log_dropped_total", "Number of entries dropped.", "reason")
+	WriteErrors  = Default.Counter("log_write_errors_total", "Number of errors returned by providers.", "provider")
+	HookErrors   = Default.Counter("log_hook_errors_total", "Number of errors returned or panics raised by hooks.")
+	WriteLatency = Default.Histogram("log_write_duration_seconds", "Latency of writing entries to providers.", nil, "provider")
+	QueueDepth   = Default.Gauge("log_queue_depth", "Number of entries in queues.", "queue")
+	Rotations    = Default.Counter("log_rotations_total", "Number of log files created by rotation.", "dir")
+	Deletions    = Default.Counter("log_retention_deletions_total", "Number of log files deleted by retention.", "dir")
+)
@@ -0,0 +1,381 @@
+// Package metrics implements counters, gauges and histograms exposed in Prometheus
+// text exposition format without depending on the Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// metric types
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// DefBuckets are default buckets of histograms in seconds
+var DefBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
+// Registry holds metrics, it implements http.Handler which serves metrics in
+// Prometheus text format
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]*Vec
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*Vec)}
+}
+
+// Default is the default registry which holds metrics of logging
+var Default = NewRegistry()
+
+// Handler returns a http.Handler which serves metrics of Default registry
+func Handler() http.Handler { return Default }
+
+// Vec is a metric with labels, children are created by With
+type Vec struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+
+	mu       sync.RWMutex
+	children map[string]*child
+}
+
+// child is a metric with label values
+type child struct {
+	// accessed atomically, they must be 64-bit aligned on 32-bit platforms
+	value uint64 // bits of float64
+	sum   uint64 // bits of float64
+
+	values []string
+	fn     func() float64 // guarded by mu of the Vec
+	counts []uint64       // counts of buckets and +Inf for histograms
+}
+
+// Counter creates a counter, the existing one is returned if it's registered already.
+// It panics if the name is registered as a different metric.
+func (r *Registry) Counter(name, help string, labels ...string) *Vec {
+	return r.mustRegister(TypeCounter, name, help, nil, labels)
+}
+
+// Gauge creates a gauge, the existing one is returned if it's registered already.
+// It panics if the name is registered as a different metric.
+func (r *Registry) Gauge(name, help string, labels ...string) *Vec {
+	return r.mustRegister(TypeGauge, name, help, nil, labels)
+}
+
+// Histogram creates a histogram, DefBuckets is used if buckets is nil, the existing
+// one is returned if it's registered already. It panics if the name is registered
+// as a different metric.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Vec {
+	return r.mustRegister(TypeHistogram, name, help, buckets, labels)
+}
+
+// Register creates a metric of type typ, buckets are used by histograms only. The existing
+// one is returned if it's registered already, an error is returned if the name is
+// registered as a different metric.
+func (r *Registry) Register(typ, name, help string, buckets []float64, labels ...string) (*Vec, error) {
+	v := &Vec{name: name, help: help, typ: typ, labels: labels}
+	switch typ {
+	case TypeCounter, TypeGauge:
+	case TypeHistogram:
+		if buckets == nil {
+			buckets = DefBuckets
+		}
+		v.buckets = append([]float64(nil), buckets...)
+		sort.Float64s(v.buckets)
+	default:
+		return nil, fmt.Errorf("metrics: unsupported type %q of %s", typ, name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.metrics[name]; ok {
+		if !old.same(v) {
+			return nil, fmt.Errorf("metrics: %s registered as a different metric", name)
+		}
+		return old, nil
+	}
+	v.children = make(map[string]*child)
+	r.metrics[name] = v
+	return v, nil
+}
+
+func (r *Registry) mustRegister(typ, name, help string, buckets []float64, labels []string) *Vec {
+	v, err := r.Register(typ, name, help, buckets, labels...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return v
+}
+
+// same reports whether v and v2 have the same type, labels and buckets
+func (v *Vec) same(v2 *Vec) bool {
+	if v.typ != v2.typ || len(v.labels) != len(v2.labels) || len(v.buckets) != len(v2.buckets) {
+		return false
+	}
+	for i := range v.labels {
+		if v.labels[i] != v2.labels[i] {
+			return false
+		}
+	}
+	for i := range v.buckets {
+		if v.buckets[i] != v2.buckets[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Unregister removes the metric
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.metrics, name)
+}
+
+// Name returns name of the metric
+func (v *Vec) Name() string { return v.name }
+
+// Type returns type of the metric: counter, gauge or histogram
+func (v *Vec) Type() string { return v.typ }
+
+// With returns the metric with label values, it's created if not found
+func (v *Vec) With(values ...string) *Metric {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s requires %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	c, ok := v.children[key]
+	v.mu.RUnlock()
+	if !ok {
+		v.mu.Lock()
+		if c, ok = v.children[key]; !ok {
+			c = &child{values: append([]string(nil), values...)}
+			if v.typ == TypeHistogram {
+				c.counts = make([]uint64, len(v.buckets)+1)
+			}
+			v.children[key] = c
+		}
+		v.mu.Unlock()
+	}
+	return &Metric{vec: v, c: c}
+}
+
+// Func sets the function which returns value of the metric with label values,
+// it's called while metrics collected
+func (v *Vec) Func(fn func() float64, values ...string) {
+	m := v.With(values...)
+	v.mu.Lock()
+	m.c.fn = fn
+	v.mu.Unlock()
+}
+
+// fn returns the function of c which is set by Func
+func (v *Vec) fn(c *child) func() float64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return c.fn
+}
+
+// Delete removes the metric with label values, it returns false if not found
+func (v *Vec) Delete(values ...string) bool {
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.children[key]; !ok {
+		return false
+	}
+	delete(v.children, key)
+	return true
+}
+
+// Reset removes all children
+func (v *Vec) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.children = make(map[string]*child)
+}
+
+// Metric is a counter, gauge or histogram with label values
+type Metric struct {
+	vec *Vec
+	c   *child
+}
+
+func addFloat(addr *uint64, delta float64) {
+	for {
+		old := atomic.LoadUint64(addr)
+		if atomic.CompareAndSwapUint64(addr, old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+// Inc adds 1 to the counter or gauge
+func (m *Metric) Inc() { m.Add(1) }
+
+// Add adds delta to the counter or gauge
+func (m *Metric) Add(delta float64) { addFloat(&m.c.value, delta) }
+
+// Set sets value of the gauge
+func (m *Metric) Set(value float64) { atomic.StoreUint64(&m.c.value, math.Float64bits(value)) }
+
+// Value returns value of the counter or gauge, or count of the histogram
+func (m *Metric) Value() float64 {
+	if m.vec.typ == TypeHistogram {
+		var count uint64
+		for i := range m.c.counts {
+			count += atomic.LoadUint64(&m.c.counts[i])
+		}
+		return float64(count)
+	}
+	if fn := m.vec.fn(m.c); fn != nil {
+		return fn()
+	}
+	return math.Float64frombits(atomic.LoadUint64(&m.c.value))
+}
+
+// Observe adds an observation to the histogram
+func (m *Metric) Observe(value float64) {
+	// counts are cumulative while exported, here every observation is counted by
+	// the first bucket which holds it, the last one is +Inf
+	i := sort.SearchFloat64s(m.vec.buckets, value)
+	atomic.AddUint64(&m.c.counts[i], 1)
+	addFloat(&m.c.sum, value)
+}
+
+// ServeHTTP implements http.Handler
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// WriteTo writes all metrics in Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	vecs := make([]*Vec, 0, len(r.metrics))
+	for _, v := range r.metrics {
+		vecs = append(vecs, v)
+	}
+	r.mu.Unlock()
+	sort.Slice(vecs, func(i, j int) bool { return vecs[i].name < vecs[j].name })
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, v := range vecs {
+		v.writeTo(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (v *Vec) writeTo(w *bufio.Writer) {
+	v.mu.RLock()
+	children := make([]*child, 0, len(v.children))
+	for _, c := range v.children {
+		children = append(children, c)
+	}
+	v.mu.RUnlock()
+	if len(children) == 0 {
+		return
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return strings.Join(children[i].values, "\xff") < strings.Join(children[j].values, "\xff")
+	})
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.typ)
+	for _, c := range children {
+		if v.typ != TypeHistogram {
+			value := math.Float64frombits(atomic.LoadUint64(&c.value))
+			if fn := v.fn(c); fn != nil {
+				value = fn()
+			}
+			writeSample(w, v.name, v.labels, c.values, "", "", value)
+			continue
+		}
+		var count uint64
+		for i, bound := range v.buckets {
+			count += atomic.LoadUint64(&c.counts[i])
+			writeSample(w, v.name+"_bucket", v.labels, c.values, "le", formatFloat(bound), float64(count))
+		}
+		count += atomic.LoadUint64(&c.counts[len(v.buckets)])
+		writeSample(w, v.name+"_bucket", v.labels, c.values, "le", "+Inf", float64(count))
+		writeSample(w, v.name+"_sum", v.labels, c.values, "", "", math.Float64frombits(atomic.LoadUint64(&c.sum)))
+		writeSample(w, v.name+"_count", v.labels, c.values, "", "", float64(count))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, label, values[i])
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func writeLabel(w *bufio.Writer, label, value string) {
+	w.WriteString(label)
+	w.WriteString(`="`)
+	w.WriteString(labelReplacer.Replace(value))
+	w.WriteByte('"')
+}
+
+var (
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeHelp(s string) string { return helpReplacer.Replace(s) }
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("test_total", "Test counter.", "kind")
+	c.With("a").Inc()
+	c.With("a").Add(2)
+	c.With(`b"\`).Inc()
+	assert.Equal(t, c, r.Counter("test_total", "Test counter.", "kind"))
+	assert.Equal(t, float64(3), c.With("a").Value())
+	assert.Panics(t, func() { r.Gauge("test_total", "", "kind") })
+	_, err := r.Register(TypeCounter, "test_total", "", nil, "other")
+	assert.NotNil(t, err)
+	_, err = r.Register("summary", "test_summary", "", nil)
+	assert.NotNil(t, err)
+	v, err := r.Register(TypeCounter, "test_total", "", nil, "kind")
+	assert.Nil(t, err)
+	assert.Equal(t, c, v)
+	assert.Panics(t, func() { c.With("a", "b") })
+
+	g := r.Gauge("test_depth", "Test gauge\nwith newline.")
+	g.Func(func() float64 { return 7 })
+
+	h := r.Histogram("test_seconds", "Test histogram.", []float64{1, 0.1}, "op")
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		h.With("x").Observe(v)
+	}
+	assert.Equal(t, float64(4), h.With("x").Value())
+
+	r.Gauge("test_empty", "Not written without children.")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Equal(t, `# HELP test_depth Test gauge\nwith newline.
+# TYPE test_depth gauge
+test_depth 7
+# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{op="x",le="0.1"} 2
+test_seconds_bucket{op="x",le="1"} 3
+test_seconds_bucket{op="x",le="+Inf"} 4
+test_seconds_sum{op="x"} 2.65
+test_seconds_count{op="x"} 4
+# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{kind="a"} 3
+test_total{kind="b\"\\"} 1
+`, string(body))
+
+	r.Unregister("test_seconds")
+	c.Reset()
+	var sb strings.Builder
+	_, err = r.WriteTo(&sb)
+	assert.Nil(t, err)
+	assert.Equal(t, "# HELP test_depth Test gauge\\nwith newline.\n# TYPE test_depth gauge\ntest_depth 7\n", sb.String())
+}
+
+func TestFuncConcurrent(t *testing.T) {
+	r := NewRegistry()
+	g := r.Gauge("test_depth", "", "queue")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			n := float64(i)
+			g.Func(func() float64 { return n }, "q")
+		}
+	}()
+	// funcs are set while metrics are collected
+	for i := 0; i < 100; i++ {
+		r.WriteTo(ioutil.Discard)
+		g.With("q").Value()
+	}
+	<-done
+	assert.Equal(t, float64(99), g.With("q").Value())
+}
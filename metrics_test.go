@@ -0,0 +1,46 @@
+package log
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+)
+
+func TestMetrics(t *testing.T) {
+	defer InitWithLogger(logger.NewStdLogger())
+	InitWithLogger(logger.NewStdLogger())
+	_, err := Stats()
+	assert.Equal(t, ErrUnsupported, err)
+
+	cfg := &Config{
+		Level:   "info",
+		Header:  "none",
+		Sync:    true,
+		Outputs: []OutputConfig{{Type: "config_test"}, {Type: "config_test", MinLevel: "error"}},
+	}
+	assert.Nil(t, InitWithConfig(cfg))
+	configTestBuffer.Reset()
+	Info("hello")
+	Error("failed")
+
+	stats, err := Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), stats.Entries[LvINFO])
+	assert.Equal(t, uint64(1), stats.Entries[LvERROR])
+	assert.Equal(t, uint64(len("hello\nfailed\n")), stats.Bytes)
+
+	w := httptest.NewRecorder()
+	HTTPHandlerMetrics().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(w.Body)
+	assert.Contains(t, string(body), `log_entries_total{level="INFO",provider="logger"} 1`)
+	// metrics of providers are accumulated by other tests
+	assert.Contains(t, string(body), `log_entries_total{level="INFO",provider="config_test#0"} `)
+	assert.Contains(t, string(body), `log_entries_total{level="INFO",provider="config_test#1"} 0`)
+	assert.Contains(t, string(body), `log_written_bytes_total{provider="logger"} 13`)
+	assert.Contains(t, string(body), `log_queue_depth{queue="logger"} 0`)
+	assert.Contains(t, string(body), `log_dropped_total{reason="queue_timeout"} 0`)
+}
@@ -0,0 +1,330 @@
+package provider
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mkideal/log/logger"
+	"github.com/mkideal/log/metrics"
+)
+
+// overflow policies of AsyncProvider
+const (
+	OverflowBlock   = "block"    // blocks the caller until the queue has room
+	OverflowDropNew = "drop_new" // drops the entry being written
+	OverflowDropOld = "drop_old" // drops the oldest entry in the queue
+)
+
+// AsyncOpts represents options of AsyncProvider
+type AsyncOpts struct {
+	QueueSize int    `json:"queue_size"` // capacity of the queue(default: 1024)
+	BatchSize int    `json:"batch_size"` // max number of entries written in a batch(default: 64)
+	Overflow  string `json:"overflow"`   // overflow policy: block, drop_new or drop_old(default: drop_new)
+	Name      string `json:"name"`       // exports depth of the queue as log_queue_depth{queue=name} until closed if not empty, it should be unique
+}
+
+func (opts *AsyncOpts) setDefaults() {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 64
+	}
+	if opts.Overflow == "" {
+		opts.Overflow = OverflowDropNew
+	}
+}
+
+func (opts *AsyncOpts) validate() error {
+	switch opts.Overflow {
+	case OverflowBlock, OverflowDropNew, OverflowDropOld:
+		return nil
+	}
+	return fmt.Errorf("unsupported overflow policy %q", opts.Overflow)
+}
+
+// AsyncStats represents metrics of AsyncProvider
+type AsyncStats struct {
+	Queued     int           // number of entries in the queue
+	Capacity   int           // capacity of the queue
+	Written    uint64        // number of entries written successfully
+	Dropped    uint64        // number of entries dropped by overflow policy
+	Errors     uint64        // number of entries failed to write
+	LastError  error         // the last error returned by the inner provider, nil if no error
+	AvgLatency time.Duration // average time from queued to written
+	MaxLatency time.Duration // max time from queued to written
+}
+
+// AsyncProvider writes entries to the inner provider in a worker goroutine by a bounded
+// queue, entries are written in batches. Errors of the inner provider are counted instead
+// of returned. It's composable, e.g.
+//
+//	NewMixProvider(file, NewAsync(network, AsyncOpts{Overflow: OverflowDropOld}))
+type AsyncProvider struct {
+	// 64-bit counters are accessed atomically, they must be 64-bit aligned on 32-bit platforms
+	written, dropped, errors uint64
+	latency, maxLatency      int64 // nanoseconds, latency is the total
+
+	provider logger.Provider
+	opts     AsyncOpts
+	queue    chan asyncItem
+	control  chan asyncCall // commands are never queued, so they are never dropped
+	done     chan struct{}
+
+	entries []logger.Entry // reused by writeBatch
+
+	mu     sync.RWMutex // write lock is held while closing
+	closed bool
+
+	lastError atomic.Value // errorHolder
+}
+
+type errorHolder struct{ err error }
+
+// asyncItem is an entry written by the worker goroutine
+type asyncItem struct {
+	entry        logger.Entry // nil if written by Write
+	level        logger.Level
+	headerLength int
+	data         []byte
+	queued       time.Time
+}
+
+// asyncCall is a command executed by the worker goroutine
+type asyncCall struct {
+	fn     func() error
+	result chan error
+}
+
+// NewAsync creates an AsyncProvider which writes entries to p, an unsupported overflow
+// policy is reported as LastError of Stats, and drop_new is used instead.
+func NewAsync(p logger.Provider, opts AsyncOpts) *AsyncProvider {
+	opts.setDefaults()
+	a := &AsyncProvider{
+		provider: p,
+		opts:     opts,
+		queue:    make(chan asyncItem, opts.QueueSize),
+		control:  make(chan asyncCall),
+		done:     make(chan struct{}),
+	}
+	a.lastError.Store(errorHolder{})
+	if err := opts.validate(); err != nil {
+		a.opts.Overflow = OverflowDropNew
+		a.lastError.Store(errorHolder{err})
+	}
+	if opts.Name != "" {
+		metrics.QueueDepth.Func(func() float64 { return float64(len(a.queue)) }, opts.Name)
+	}
+	go a.run()
+	return a
+}
+
+func (a *AsyncProvider) run() {
+	defer close(a.done)
+	batch := make([]asyncItem, 0, a.opts.BatchSize)
+	for {
+		select {
+		case item, ok := <-a.queue:
+			if !ok {
+				return
+			}
+			batch = append(batch[:0], item)
+			a.drain(&batch, a.opts.BatchSize)
+		case call := <-a.control:
+			// writes entries queued before the command
+			for n := len(a.queue); n > 0; n -= len(batch) {
+				batch = batch[:0]
+				if a.drain(&batch, n); len(batch) == 0 {
+					break
+				}
+			}
+			call.result <- call.fn()
+		}
+	}
+}
+
+// drain appends queued items to batch until it has n items or the queue is empty,
+// and then writes the batch
+func (a *AsyncProvider) drain(batch *[]asyncItem, n int) {
+	if n > a.opts.BatchSize {
+		n = a.opts.BatchSize
+	}
+loop:
+	for len(*batch) < n {
+		select {
+		case item, ok := <-a.queue:
+			if !ok {
+				break loop
+			}
+			*batch = append(*batch, item)
+		default:
+			break loop
+		}
+	}
+	a.writeBatch(*batch)
+}
+
+// writeBatch writes items in order, consecutive entries are written by logger.WriteBatch
+func (a *AsyncProvider) writeBatch(batch []asyncItem) {
+	entries := a.entries[:0]
+	for i := 0; i < len(batch); i++ {
+		item := &batch[i]
+		switch {
+		case item.entry == nil:
+			a.done1(item.queued, a.provider.Write(item.level, item.headerLength, item.data))
+		default:
+			j := i
+			for ; j < len(batch) && batch[j].entry != nil; j++ {
+				entries = append(entries, batch[j].entry)
+			}
+			err := logger.WriteBatch(a.provider, entries)
+			for k := i; k < j; k++ {
+				a.done1(batch[k].queued, err)
+			}
+			for k := range entries {
+				entries[k] = nil
+			}
+			entries = entries[:0]
+			i = j - 1
+		}
+	}
+	for i := range batch {
+		batch[i] = asyncItem{}
+	}
+	a.entries = entries
+}
+
+// done1 records result of an entry
+func (a *AsyncProvider) done1(queued time.Time, err error) {
+	if err != nil {
+		atomic.AddUint64(&a.errors, 1)
+		a.lastError.Store(errorHolder{err})
+		return
+	}
+	atomic.AddUint64(&a.written, 1)
+	latency := int64(time.Since(queued))
+	atomic.AddInt64(&a.latency, latency)
+	for {
+		max := atomic.LoadInt64(&a.maxLatency)
+		if latency <= max || atomic.CompareAndSwapInt64(&a.maxLatency, max, latency) {
+			break
+		}
+	}
+}
+
+func (a *AsyncProvider) push(item asyncItem) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return errClosed
+	}
+	item.queued = time.Now()
+	switch a.opts.Overflow {
+	case OverflowBlock:
+		a.queue <- item
+		return nil
+	case OverflowDropOld:
+		for {
+			select {
+			case a.queue <- item:
+				return nil
+			default:
+			}
+			select {
+			case <-a.queue:
+				a.drop()
+			default:
+			}
+		}
+	default:
+		select {
+		case a.queue <- item:
+		default:
+			a.drop()
+		}
+		return nil
+	}
+}
+
+// drop counts an entry dropped by overflow policy
+func (a *AsyncProvider) drop() {
+	atomic.AddUint64(&a.dropped, 1)
+	metrics.Dropped.With(metrics.DropQueueFull).Inc()
+}
+
+// Write copies data and queues it
+func (a *AsyncProvider) Write(level logger.Level, headerLength int, data []byte) error {
+	return a.push(asyncItem{level: level, headerLength: headerLength, data: append([]byte(nil), data...)})
+}
+
+// WriteEntry clones the entry and queues it
+func (a *AsyncProvider) WriteEntry(e logger.Entry) error {
+	return a.push(asyncItem{entry: e.Clone()})
+}
+
+// do calls fn in the worker goroutine after queued entries written
+func (a *AsyncProvider) do(fn func() error) error {
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		return errClosed
+	}
+	result := make(chan error, 1)
+	a.control <- asyncCall{fn: fn, result: result}
+	a.mu.RUnlock()
+	return <-result
+}
+
+// Flush waits until entries queued before it written
+func (a *AsyncProvider) Flush() error {
+	return a.do(func() error { return nil })
+}
+
+// Reopen reopens the inner provider after queued entries written
+func (a *AsyncProvider) Reopen() error {
+	return a.do(func() error { return logger.Reopen(a.provider) })
+}
+
+// Health implements HealthReporter interface, it reports health of the inner provider
+func (a *AsyncProvider) Health() Health {
+	if hr, ok := a.provider.(HealthReporter); ok {
+		return hr.Health()
+	}
+	return Health{}
+}
+
+// Stats returns metrics of the provider
+func (a *AsyncProvider) Stats() AsyncStats {
+	stats := AsyncStats{
+		Queued:     len(a.queue),
+		Capacity:   cap(a.queue),
+		Written:    atomic.LoadUint64(&a.written),
+		Dropped:    atomic.LoadUint64(&a.dropped),
+		Errors:     atomic.LoadUint64(&a.errors),
+		LastError:  a.lastError.Load().(errorHolder).err,
+		MaxLatency: time.Duration(atomic.LoadInt64(&a.maxLatency)),
+	}
+	if stats.Written > 0 {
+		stats.AvgLatency = time.Duration(atomic.LoadInt64(&a.latency) / int64(stats.Written))
+	}
+	return stats
+}
+
+// Close writes queued entries and closes the inner provider
+func (a *AsyncProvider) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return errClosed
+	}
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+	<-a.done
+	if a.opts.Name != "" {
+		metrics.QueueDepth.Delete(a.opts.Name)
+	}
+	return a.provider.Close()
+}
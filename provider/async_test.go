@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+)
+
+// blockingProvider blocks writing until unblocked
+type blockingProvider struct {
+	capture
+	unblock chan struct{}
+}
+
+func (p *blockingProvider) Write(level logger.Level, headerLength int, data []byte) error {
+	<-p.unblock
+	return p.capture.Write(level, headerLength, data)
+}
+
+func TestAsyncProvider(t *testing.T) {
+	for _, tt := range []struct {
+		overflow string
+		output   string
+		dropped  uint64
+	}{
+		{OverflowDropNew, "0\n1\n2\n", 2},
+		{OverflowDropOld, "0\n3\n4\n", 2},
+		{OverflowBlock, "0\n1\n2\n3\n4\n", 0},
+	} {
+		inner := &blockingProvider{unblock: make(chan struct{})}
+		p := NewAsync(inner, AsyncOpts{QueueSize: 2, Overflow: tt.overflow})
+		assert.Nil(t, p.Write(logger.INFO, 0, []byte("0\n")))
+		// waits until the worker blocked by the first entry
+		for p.Stats().Queued > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for _, s := range []string{"1\n", "2\n", "3\n", "4\n"} {
+				assert.Nil(t, p.Write(logger.INFO, 0, []byte(s)))
+			}
+		}()
+		if tt.overflow != OverflowBlock {
+			<-done
+		}
+		close(inner.unblock)
+		<-done
+		assert.Nil(t, p.Flush())
+		stats := p.Stats()
+		assert.Equal(t, tt.output, inner.String(), tt.overflow)
+		assert.Equal(t, tt.dropped, stats.Dropped, tt.overflow)
+		assert.Equal(t, 5-tt.dropped, stats.Written, tt.overflow)
+		assert.Equal(t, 2, stats.Capacity)
+		assert.True(t, stats.MaxLatency >= stats.AvgLatency && stats.AvgLatency > 0)
+		assert.Nil(t, p.Close())
+		assert.Equal(t, errClosed, p.Write(logger.INFO, 0, []byte("closed\n")))
+	}
+
+	p := NewAsync(new(capture), AsyncOpts{Overflow: "never"})
+	assert.NotNil(t, p.Stats().LastError)
+	assert.Nil(t, p.Close())
+}
+
+func TestAsyncProviderDropOldCommand(t *testing.T) {
+	inner := &blockingProvider{unblock: make(chan struct{})}
+	p := NewAsync(inner, AsyncOpts{QueueSize: 2, Overflow: OverflowDropOld})
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("0\n")))
+	for p.Stats().Queued > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("1\n")))
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("2\n")))
+	flushed := make(chan error)
+	go func() { flushed <- p.Flush() }()
+
+	// the command is never dropped or reordered by entries which overflow the queue
+	for _, s := range []string{"3\n", "4\n", "5\n"} {
+		assert.Nil(t, p.Write(logger.INFO, 0, []byte(s)))
+	}
+	close(inner.unblock)
+	assert.Nil(t, <-flushed)
+	assert.Equal(t, "0\n4\n5\n", inner.String())
+	assert.Equal(t, uint64(3), p.Stats().Dropped)
+	assert.Nil(t, p.Close())
+}
+
+func TestAsyncProviderInMix(t *testing.T) {
+	var (
+		direct = new(capture)
+		inner  = new(capture)
+		async  = NewAsync(inner, AsyncOpts{})
+		p      = NewMixProvider(direct, async)
+	)
+	l := logger.NewSync(p)
+	l.SetLevel(logger.TRACE)
+	l.NoHeader()
+	l.Info(0, "hello")
+	l.Warn(0, "world")
+	assert.Nil(t, async.Flush())
+	assert.Equal(t, "hello\nworld\n", direct.String())
+	assert.Equal(t, "hello\nworld\n", inner.String())
+	l.Quit()
+	assert.Nil(t, p.Close())
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mkideal/log/logger"
+)
+
+// Banner formats
+const (
+	BannerText = "text" // banner is rendered by the template
+	BannerJSON = "json" // banner is written as a JSON line like entries of JSON provider
+)
+
+// defaultBanner is the template of banner written at the beginning of every file
+const defaultBanner = `File opened at: {{.Time.Format "2006/01/02 15:04:05"}}
+Built with {{.Compiler}} {{.GoVersion}} for {{.OS}}/{{.Arch}}
+`
+
+// BannerInfo holds fields available in banner template, e.g.
+//
+//	{{.App}} {{.Version}} started on {{.Hostname}} with pid {{.Pid}}: {{.CmdLine}}
+type BannerInfo struct {
+	Time      time.Time `json:"-"`
+	Filename  string    `json:"filename"`
+	App       string    `json:"app"`
+	Version   string    `json:"version,omitempty"`
+	Hostname  string    `json:"hostname"`
+	Pid       int       `json:"pid"`
+	Args      []string  `json:"args"`
+	CmdLine   string    `json:"-"`
+	Compiler  string    `json:"compiler"`
+	GoVersion string    `json:"go_version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+}
+
+func parseBanner(banner string) (*template.Template, error) {
+	switch banner {
+	case "none":
+		return nil, nil
+	case "":
+		banner = defaultBanner
+	}
+	return template.New("banner").Parse(banner)
+}
+
+func newBannerInfo(now time.Time, filename, version string) *BannerInfo {
+	hostname, _ := os.Hostname()
+	return &BannerInfo{
+		Time:      now,
+		Filename:  filename,
+		App:       filepath.Base(os.Args[0]),
+		Version:   version,
+		Hostname:  hostname,
+		Pid:       pid,
+		Args:      os.Args,
+		CmdLine:   strings.Join(os.Args, " "),
+		Compiler:  runtime.Compiler,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+}
+
+// renderBanner renders the banner as text by tmpl, or as a JSON line if format is json
+func renderBanner(tmpl *template.Template, format string, info *BannerInfo) ([]byte, error) {
+	if format == BannerJSON {
+		data, err := json.Marshal(info)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(&jsonEntry{
+			Time:  info.Time.Format(time.RFC3339Nano),
+			Level: logger.INFO,
+			Msg:   "file opened",
+			Data:  data,
+		})
+		return append(b, '\n'), err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, info); err != nil {
+		return nil, err
+	}
+	if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+)
+
+// entryRecorder records clones of entries
+type entryRecorder struct {
+	entries []logger.Entry
+}
+
+func (p *entryRecorder) Write(level logger.Level, headerLength int, data []byte) error { return nil }
+func (p *entryRecorder) WriteEntry(e logger.Entry) error {
+	p.entries = append(p.entries, e.Clone())
+	return nil
+}
+func (p *entryRecorder) Close() error { return nil }
+
+// newEntries creates n entries, every errorEvery-th entry is an ERROR entry if errorEvery > 0
+func newEntries(n, errorEvery int) []logger.Entry {
+	p := new(entryRecorder)
+	l := logger.NewSync(p)
+	l.SetLevel(logger.TRACE)
+	for i := 0; i < n; i++ {
+		if errorEvery > 0 && i%errorEvery == 0 {
+			l.Error(0, "entry %d", i)
+		} else {
+			l.Info(0, "entry %d", i)
+		}
+	}
+	return p.entries
+}
+
+// countingWriter counts calls of Write
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestConsoleWriteBatch(t *testing.T) {
+	var (
+		stdout, stderr countingWriter
+		p              = NewConsoleWithWriter("", &stdout, &stderr).(*Console)
+		entries        = newEntries(10, 4) // errors: 0, 4, 8
+	)
+	assert.Nil(t, p.WriteBatch(entries))
+	assert.Equal(t, 3, stderr.writes)
+	assert.Equal(t, 3, stdout.writes)
+	assert.Equal(t, 3, strings.Count(stderr.String(), "\n"))
+	assert.Equal(t, 7, strings.Count(stdout.String(), "\n"))
+	assert.True(t, strings.HasSuffix(stdout.String(), "entry 9\n"))
+}
+
+func TestFileWriteBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_file_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	p := NewFile(`{"dir":"` + filepath.ToSlash(dir) + `","filename":"app","nosymlink":true,"banner":"none","sync":"always"}`).(*File)
+	defer p.Close()
+	entries := newEntries(10, 0)
+	assert.Nil(t, p.WriteBatch(entries))
+	content := readFile(t, p.path)
+	assert.Equal(t, 10, strings.Count(content, "\n"))
+	assert.True(t, strings.HasSuffix(content, "entry 9\n"))
+}
+
+func BenchmarkConsoleWrite(b *testing.B) {
+	entries := newEntries(64, 16)
+	for _, batch := range []bool{false, true} {
+		b.Run(fmt.Sprintf("batch=%v", batch), func(b *testing.B) {
+			var w countingWriter
+			p := NewConsoleWithWriter("", &w, &w).(*Console)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if batch {
+					p.WriteBatch(entries)
+				} else {
+					for _, e := range entries {
+						d := logger.Detailed(e)
+						p.Write(d.Level(), d.HeaderLength(), d.Bytes())
+					}
+				}
+				w.Reset()
+			}
+			b.ReportMetric(float64(w.writes)/float64(b.N*len(entries)), "writes/entry")
+		})
+	}
+}
+
+func BenchmarkFileWriteBatch(b *testing.B) {
+	dir, err := ioutil.TempDir("", "log_file_bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	entries := newEntries(64, 0)
+	for _, sync := range []string{SyncAlways, SyncInterval} {
+		for _, batch := range []bool{false, true} {
+			b.Run(fmt.Sprintf("sync=%s/batch=%v", sync, batch), func(b *testing.B) {
+				p := NewFile(`{"dir":"` + filepath.ToSlash(dir) + `","filename":"bench","nosymlink":true,"sync":"` + sync + `"}`).(*File)
+				defer p.Close()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if batch {
+						p.WriteBatch(entries)
+					} else {
+						for _, e := range entries {
+							d := logger.Detailed(e)
+							p.Write(d.Level(), d.HeaderLength(), d.Bytes())
+						}
+					}
+				}
+			})
+		}
+	}
+}
@@ -67,7 +67,7 @@ func (p *ColoredConsole) Close() error { return nil }
 
 func (p *ColoredConsole) color(level logger.Level) []byte {
 	switch level {
-	case logger.FATAL:
+	case logger.PANIC, logger.FATAL:
 		return mag
 	case logger.ERROR:
 		return red
@@ -1,8 +1,10 @@
 package provider
 
 import (
+	"bytes"
 	"io"
 	"os"
+	"sync"
 
 	"github.com/mkideal/log/logger"
 )
@@ -54,5 +56,39 @@ func (p *Console) Write(level logger.Level, headerLength int, data []byte) error
 	return err
 }
 
+// WriteBatch implements logger.BatchProvider interface, consecutive entries written
+// to the same writer are written by one Write
+func (p *Console) WriteBatch(entries []logger.Entry) error {
+	var (
+		errs errorList
+		buf  = consoleBufferPool.Get().(*bytes.Buffer)
+		last io.Writer
+	)
+	for _, e := range entries {
+		w := p.stdout
+		if e.Level() <= p.config.ToStderrLevel {
+			w = p.stderr
+		}
+		if w != last && buf.Len() > 0 {
+			_, err := last.Write(buf.Bytes())
+			errs.tryPush(err)
+			buf.Reset()
+		}
+		last = w
+		buf.Write(logger.Detailed(e).Bytes())
+	}
+	if buf.Len() > 0 {
+		_, err := last.Write(buf.Bytes())
+		errs.tryPush(err)
+	}
+	buf.Reset()
+	consoleBufferPool.Put(buf)
+	return errs.err()
+}
+
+var consoleBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
 // Close implements Provider.Close method
 func (p *Console) Close() error { return nil }
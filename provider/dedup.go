@@ -0,0 +1,228 @@
+package provider
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mkideal/log/logger"
+)
+
+// DedupKey represents parts of entry which identify repeated entries
+type DedupKey int
+
+const (
+	DedupByLevel   DedupKey = 1 << iota // level of entry
+	DedupByCaller                       // file:line in header
+	DedupByMessage                      // content after header
+
+	DedupByAll = DedupByLevel | DedupByCaller | DedupByMessage
+)
+
+// DedupOpts represents options of dedup provider
+type DedupOpts struct {
+	Window  time.Duration // entries with the same key in the window are collapsed(default: 1s)
+	Keys    DedupKey      // parts of entry which identify repeated entries(default: DedupByAll)
+	MaxKeys int           // max number of tracked keys, the least recently used key is evicted(default: 1024)
+}
+
+func (opts *DedupOpts) setDefaults() {
+	if opts.Window <= 0 {
+		opts.Window = time.Second
+	}
+	if opts.Keys == 0 {
+		opts.Keys = DedupByAll
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = 1024
+	}
+}
+
+type dedupRecord struct {
+	key    string
+	level  logger.Level
+	first  time.Time
+	header []byte // header of the latest repeated entry
+	count  int    // number of suppressed entries
+}
+
+// followUp is a "repeated N times" entry, it's written after d.mu unlocked
+type followUp struct {
+	level        logger.Level
+	headerLength int
+	data         []byte
+}
+
+// Dedup is a provider which collapses repeated entries within a time window into
+// a single entry plus a "repeated N times" follow-up entry.
+type Dedup struct {
+	provider logger.Provider
+	opts     DedupOpts
+
+	mu      sync.Mutex
+	records map[string]*list.Element
+	lru     *list.List // front is the most recently used
+	closed  bool
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// NewDedup creates a dedup provider in front of provider p
+func NewDedup(p logger.Provider, opts DedupOpts) logger.Provider {
+	opts.setDefaults()
+	d := &Dedup{
+		provider: p,
+		opts:     opts,
+		records:  make(map[string]*list.Element),
+		lru:      list.New(),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go d.loop()
+	return d
+}
+
+func (d *Dedup) loop() {
+	defer close(d.done)
+	interval := d.opts.Window / 2
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.mu.Lock()
+			pending := d.expire(now, nil)
+			d.mu.Unlock()
+			d.writeFollowUps(pending)
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+func (d *Dedup) key(level logger.Level, headerLength int, data []byte) string {
+	var buf bytes.Buffer
+	if d.opts.Keys&DedupByLevel != 0 {
+		buf.WriteString(level.String())
+	}
+	buf.WriteByte(0)
+	if d.opts.Keys&DedupByCaller != 0 {
+		buf.Write(callerOfHeader(data[:headerLength]))
+	}
+	buf.WriteByte(0)
+	if d.opts.Keys&DedupByMessage != 0 {
+		buf.Write(data[headerLength:])
+	}
+	return buf.String()
+}
+
+// callerOfHeader gets file:line from header `[L yyyy/MM/dd hh:mm:ss.uuu file:line] `
+func callerOfHeader(header []byte) []byte {
+	header = bytes.TrimRight(header, "] ")
+	if i := bytes.LastIndexByte(header, ' '); i >= 0 {
+		return header[i+1:]
+	}
+	return nil
+}
+
+// Write implements Provider.Write method
+func (d *Dedup) Write(level logger.Level, headerLength int, data []byte) error {
+	if headerLength < 0 || headerLength > len(data) {
+		headerLength = 0
+	}
+	key := d.key(level, headerLength, data)
+	now := time.Now()
+
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return errClosed
+	}
+	var pending []followUp
+	if elem, ok := d.records[key]; ok {
+		r := elem.Value.(*dedupRecord)
+		if now.Sub(r.first) < d.opts.Window {
+			r.count++
+			r.header = append(r.header[:0], data[:headerLength]...)
+			d.lru.MoveToFront(elem)
+			d.mu.Unlock()
+			return nil
+		}
+		pending = d.remove(elem, pending)
+	}
+	d.records[key] = d.lru.PushFront(&dedupRecord{
+		key:   key,
+		level: level,
+		first: now,
+	})
+	for d.lru.Len() > d.opts.MaxKeys {
+		pending = d.remove(d.lru.Back(), pending)
+	}
+	d.mu.Unlock()
+
+	d.writeFollowUps(pending)
+	return d.provider.Write(level, headerLength, data)
+}
+
+// expire removes records out of window and appends their follow-up entries to pending
+func (d *Dedup) expire(now time.Time, pending []followUp) []followUp {
+	for elem := d.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if now.Sub(elem.Value.(*dedupRecord).first) >= d.opts.Window {
+			pending = d.remove(elem, pending)
+		}
+		elem = prev
+	}
+	return pending
+}
+
+// remove removes the record and appends the follow-up entry to pending if needed
+func (d *Dedup) remove(elem *list.Element, pending []followUp) []followUp {
+	r := elem.Value.(*dedupRecord)
+	d.lru.Remove(elem)
+	delete(d.records, r.key)
+	if r.count == 0 {
+		return pending
+	}
+	var buf bytes.Buffer
+	buf.Write(r.header)
+	fmt.Fprintf(&buf, "last entry repeated %d times in %v\n", r.count, time.Since(r.first).Round(time.Millisecond))
+	return append(pending, followUp{level: r.level, headerLength: len(r.header), data: buf.Bytes()})
+}
+
+// writeFollowUps writes follow-up entries to inner provider, d.mu must not be held
+func (d *Dedup) writeFollowUps(pending []followUp) {
+	for _, f := range pending {
+		d.provider.Write(f.level, f.headerLength, f.data)
+	}
+}
+
+// Reopen reopens inner provider
+func (d *Dedup) Reopen() error { return logger.Reopen(d.provider) }
+
+// Close writes pending follow-up entries and closes inner provider
+func (d *Dedup) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return errClosed
+	}
+	d.closed = true
+	d.mu.Unlock()
+	close(d.quit)
+	<-d.done
+	d.mu.Lock()
+	var pending []followUp
+	for d.lru.Len() > 0 {
+		pending = d.remove(d.lru.Back(), pending)
+	}
+	d.mu.Unlock()
+	d.writeFollowUps(pending)
+	return d.provider.Close()
+}
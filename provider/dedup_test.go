@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkideal/log/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedup(t *testing.T) {
+	w := new(bytes.Buffer)
+	p := NewDedup(NewConsoleWithWriter("", w, w), DedupOpts{Window: time.Hour})
+
+	header := "[E 2000/01/02 03:04:05.006 main.go:10] "
+	write := func(level logger.Level, header, msg string) {
+		p.Write(level, len(header), []byte(header+msg))
+	}
+	for i := 0; i < 3; i++ {
+		write(logger.ERROR, header, "dependency down\n")
+	}
+	write(logger.ERROR, "[E 2000/01/02 03:04:05.006 main.go:11] ", "dependency down\n")
+	write(logger.WARN, header, "dependency down\n")
+	assert.Equal(t, 3, strings.Count(w.String(), "dependency down\n"))
+
+	p.Close()
+	lines := strings.Split(strings.TrimSpace(w.String()), "\n")
+	assert.Equal(t, 4, len(lines))
+	assert.True(t, strings.HasPrefix(lines[3], header+"last entry repeated 2 times in "), lines[3])
+}
+
+func TestDedupMaxKeys(t *testing.T) {
+	w := new(bytes.Buffer)
+	p := NewDedup(NewConsoleWithWriter("", w, w), DedupOpts{
+		Window:  time.Hour,
+		Keys:    DedupByMessage,
+		MaxKeys: 1,
+	})
+	defer p.Close()
+	p.Write(logger.INFO, 0, []byte("a\n"))
+	p.Write(logger.INFO, 0, []byte("a\n"))
+	p.Write(logger.INFO, 0, []byte("b\n"))
+	p.Write(logger.INFO, 0, []byte("a\n"))
+	assert.True(t, strings.HasPrefix(w.String(), "a\nlast entry repeated 1 times in "))
+	assert.True(t, strings.HasSuffix(w.String(), "\nb\na\n"))
+}
+
+func TestDedupTinyWindow(t *testing.T) {
+	w := new(bytes.Buffer)
+	p := NewDedup(NewConsoleWithWriter("", w, w), DedupOpts{Window: time.Nanosecond})
+	p.Write(logger.INFO, 0, []byte("a\n"))
+	assert.Nil(t, p.Close())
+	assert.Equal(t, errClosed, p.Close())
+	assert.Equal(t, errClosed, p.Write(logger.INFO, 0, []byte("closed\n")))
+	assert.Equal(t, "a\n", w.String())
+}
+
+// reentrantProvider writes back to the dedup provider while receiving follow-up entries
+type reentrantProvider struct {
+	bytes.Buffer
+	d *Dedup
+}
+
+func (p *reentrantProvider) Write(level logger.Level, headerLength int, data []byte) error {
+	p.Buffer.Write(data)
+	if bytes.HasPrefix(data, []byte("last entry repeated")) {
+		return p.d.Write(level, 0, []byte("reentered\n"))
+	}
+	return nil
+}
+
+func (p *reentrantProvider) Close() error { return nil }
+
+func TestDedupFollowUpUnlocked(t *testing.T) {
+	inner := new(reentrantProvider)
+	p := NewDedup(inner, DedupOpts{Window: time.Hour, Keys: DedupByMessage, MaxKeys: 1}).(*Dedup)
+	inner.d = p
+	defer p.Close()
+	p.Write(logger.INFO, 0, []byte("a\n"))
+	p.Write(logger.INFO, 0, []byte("a\n"))
+	p.Write(logger.INFO, 0, []byte("b\n"))
+	assert.True(t, strings.HasPrefix(inner.String(), "a\nlast entry repeated 1 times in "))
+	assert.True(t, strings.HasSuffix(inner.String(), "\nreentered\nb\n"), inner.String())
+}
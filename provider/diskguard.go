@@ -0,0 +1,364 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mkideal/log/logger"
+	"github.com/mkideal/log/metrics"
+)
+
+var errStatfsUnsupported = errors.New("statfs unsupported")
+
+// DiskGuardOpts represents options of disk-space guard. Below low thresholds, TRACE and
+// DEBUG entries are dropped; below critical thresholds, all entries but ERROR, FATAL and
+// PANIC are dropped. Zero threshold means default, negative threshold disables the check.
+type DiskGuardOpts struct {
+	Interval       logger.Duration `json:"interval"`        // interval of checking free space(default: 10s)
+	LowBytes       int64           `json:"low_bytes"`       // low threshold of free bytes(default: 1G)
+	CriticalBytes  int64           `json:"critical_bytes"`  // critical threshold of free bytes(default: 128M)
+	LowInodes      int64           `json:"low_inodes"`      // low threshold of free inodes(default: 10000)
+	CriticalInodes int64           `json:"critical_inodes"` // critical threshold of free inodes(default: 1000)
+	DeleteOldest   bool            `json:"delete_oldest"`   // deletes oldest rotated files of the writer below low thresholds, files of other processes are kept(default: false)
+	Keep           int             `json:"keep"`            // number of newest rotated files of every directory never deleted(default: 0)
+	Alert          string          `json:"alert"`           // output of the warning: stderr or stdout(default: stderr)
+}
+
+func (opts *DiskGuardOpts) setDefaults() {
+	if opts.Interval <= 0 {
+		opts.Interval = logger.Duration(10 * time.Second)
+	}
+	if opts.LowBytes == 0 {
+		opts.LowBytes = 1 << 30
+	}
+	if opts.CriticalBytes == 0 {
+		opts.CriticalBytes = 1 << 27
+	}
+	if opts.LowInodes == 0 {
+		opts.LowInodes = 10000
+	}
+	if opts.CriticalInodes == 0 {
+		opts.CriticalInodes = 1000
+	}
+	if opts.Alert == "" {
+		opts.Alert = "stderr"
+	}
+}
+
+// diskUsage represents free space of a volume
+type diskUsage struct {
+	freeBytes  int64
+	freeInodes int64
+}
+
+// disk states
+const (
+	diskNormal int32 = iota
+	diskLow
+	diskCritical
+)
+
+// diskTarget is a directory whose rotated files may be deleted by the guard
+type diskTarget struct {
+	dir   string
+	match func(name string) bool // reports whether the file in dir is a rotated file which can be deleted
+	file  *File                  // the file written in dir, nil if unknown
+}
+
+// diskGuard drops entries while free space of the volume is low, it may be shared
+// by files on the same volume, see diskGuards
+type diskGuard struct {
+	opts   DiskGuardOpts
+	alert  logger.Provider
+	statfs func(dir string) (diskUsage, error)
+
+	state int32
+
+	mu        sync.Mutex
+	dir       string // directory checked by statfs, it's the directory of the first target
+	targets   []diskTarget
+	lastCheck time.Time
+	warned    bool
+}
+
+func newDiskGuard(opts DiskGuardOpts) (*diskGuard, error) {
+	opts.setDefaults()
+	alert, err := newFallback(opts.Alert)
+	if err != nil {
+		return nil, err
+	}
+	return &diskGuard{
+		opts:   opts,
+		alert:  alert,
+		statfs: statfs,
+	}, nil
+}
+
+// add adds a target, files being written by f are never deleted
+func (g *diskGuard) add(dir string, match func(name string) bool, f *File) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.targets) == 0 {
+		g.dir = dir
+	}
+	g.targets = append(g.targets, diskTarget{dir: dir, match: match, file: f})
+}
+
+// remove removes targets of file f
+func (g *diskGuard) remove(f *File) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	targets := g.targets[:0]
+	for _, t := range g.targets {
+		if t.file != f {
+			targets = append(targets, t)
+		}
+	}
+	g.targets = targets
+}
+
+// allow reports whether entries of level should be written, dropped entries are counted
+func (g *diskGuard) allow(level logger.Level) bool {
+	allowed := true
+	switch atomic.LoadInt32(&g.state) {
+	case diskLow:
+		allowed = !level.MoreVerboseThan(logger.INFO)
+	case diskCritical:
+		allowed = !level.MoreVerboseThan(logger.ERROR)
+	}
+	if !allowed {
+		metrics.Dropped.With(metrics.DropDiskGuard).Inc()
+	}
+	return allowed
+}
+
+// level returns the most verbose level allowed, it's used by the warning
+func (g *diskGuard) level() logger.Level {
+	if atomic.LoadInt32(&g.state) == diskCritical {
+		return logger.ERROR
+	}
+	return logger.INFO
+}
+
+func (g *diskGuard) stateOf(u diskUsage) int32 {
+	below := func(v, threshold int64) bool { return threshold > 0 && v < threshold }
+	if below(u.freeBytes, g.opts.CriticalBytes) || below(u.freeInodes, g.opts.CriticalInodes) {
+		return diskCritical
+	}
+	if below(u.freeBytes, g.opts.LowBytes) || below(u.freeInodes, g.opts.LowInodes) {
+		return diskLow
+	}
+	return diskNormal
+}
+
+// check checks free space if interval elapsed, files being written by files of targets
+// and current files are never deleted. It must not be called while mu of a File is held.
+func (g *diskGuard) check(now time.Time, current ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now.Sub(g.lastCheck) < time.Duration(g.opts.Interval) || g.dir == "" {
+		return
+	}
+	g.lastCheck = now
+	u, err := g.statfs(g.dir)
+	if err != nil {
+		return
+	}
+	state := g.stateOf(u)
+	if state != diskNormal && g.opts.DeleteOldest {
+		current = append([]string(nil), current...)
+		for _, t := range g.targets {
+			if t.file != nil {
+				t.file.mu.Lock()
+				current = append(current, t.file.currentFiles()...)
+				t.file.mu.Unlock()
+			}
+		}
+		for _, file := range g.rotatedFiles(current...) {
+			if os.Remove(file) != nil {
+				continue
+			}
+			metrics.Deletions.With(filepath.Dir(file)).Inc()
+			if u, err = g.statfs(g.dir); err != nil {
+				break
+			}
+			if state = g.stateOf(u); state == diskNormal {
+				break
+			}
+		}
+	}
+	atomic.StoreInt32(&g.state, state)
+	if state == diskNormal {
+		g.warned = false
+		return
+	}
+	if !g.warned {
+		g.warned = true
+		g.alert.Write(logger.WARN, 0, []byte(fmt.Sprintf(
+			"log: free space of %s is low(%d bytes, %d inodes), entries more verbose than %s are dropped\n",
+			g.dir, u.freeBytes, u.freeInodes, g.level())))
+	}
+}
+
+// rotatedFile is a deletable rotated file
+type rotatedFile struct {
+	path    string
+	modTime time.Time
+}
+
+// rotatedFiles returns deletable rotated files of all targets from oldest to newest,
+// current files excluded, g.mu must be held
+func (g *diskGuard) rotatedFiles(current ...string) []string {
+	var files []rotatedFile
+	for _, t := range g.targets {
+		files = append(files, t.rotatedFiles(g.opts.Keep, current)...)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	var names []string
+	for _, f := range files {
+		names = append(names, f.path)
+	}
+	return names
+}
+
+// rotatedFiles returns deletable rotated files in the directory except newest keep files
+func (t diskTarget) rotatedFiles(keep int, current []string) []rotatedFile {
+	if t.match == nil {
+		return nil
+	}
+	infos, err := ioutil.ReadDir(t.dir)
+	if err != nil {
+		return nil
+	}
+	var files []os.FileInfo
+next:
+	for _, fi := range infos {
+		if !fi.Mode().IsRegular() || !t.match(fi.Name()) {
+			continue
+		}
+		name := filepath.Join(t.dir, fi.Name())
+		for _, c := range current {
+			if c != "" && filepath.Clean(c) == name {
+				continue next
+			}
+		}
+		files = append(files, fi)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	if len(files) <= keep {
+		return nil
+	}
+	files = files[:len(files)-keep]
+	rotated := make([]rotatedFile, len(files))
+	for i, fi := range files {
+		rotated[i] = rotatedFile{path: filepath.Join(t.dir, fi.Name()), modTime: fi.ModTime()}
+	}
+	return rotated
+}
+
+// diskGuards shares disk-space guards among files of a MultiFile, files on the same
+// volume share a guard, so free space of the volume is checked and warned once
+type diskGuards struct {
+	opts DiskGuardOpts
+
+	mu     sync.Mutex
+	guards map[string]*diskGuard // keyed by volume
+}
+
+func newDiskGuards(opts DiskGuardOpts) *diskGuards {
+	return &diskGuards{opts: opts, guards: make(map[string]*diskGuard)}
+}
+
+// get returns the guard of the volume of dir, it's created if not found
+func (gs *diskGuards) get(dir string) (*diskGuard, error) {
+	key, err := volumeOf(dir)
+	if err != nil {
+		key = abs(dir)
+	}
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if g, ok := gs.guards[key]; ok {
+		return g, nil
+	}
+	g, err := newDiskGuard(gs.opts)
+	if err != nil {
+		return nil, err
+	}
+	gs.guards[key] = g
+	return g, nil
+}
+
+// DiskGuard is a provider which drops entries while free space of the volume is low
+type DiskGuard struct {
+	provider logger.Provider
+	guard    *diskGuard
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+// NewDiskGuard creates a DiskGuard provider which checks free space of the volume of dir
+// and writes entries to p. Rotated files in dir whose names match the glob pattern, e.g.
+// "app.*.log", may be deleted if opts.DeleteOldest is true, so the pattern should match
+// only closed files written by p. No file is deleted if pattern is empty.
+func NewDiskGuard(p logger.Provider, dir, pattern string, opts DiskGuardOpts) (*DiskGuard, error) {
+	var match func(string) bool
+	if pattern != "" {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		match = func(name string) bool {
+			ok, _ := filepath.Match(pattern, name)
+			return ok
+		}
+	}
+	guard, err := newDiskGuard(opts)
+	if err != nil {
+		return nil, err
+	}
+	guard.add(dir, match, nil)
+	d := &DiskGuard{
+		provider: p,
+		guard:    guard,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	guard.check(time.Now())
+	go d.run()
+	return d, nil
+}
+
+func (d *DiskGuard) run() {
+	defer close(d.done)
+	ticker := time.NewTicker(time.Duration(d.guard.opts.Interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.quit:
+			return
+		case now := <-ticker.C:
+			d.guard.check(now)
+		}
+	}
+}
+
+// Write writes entries to inner provider if disk space allowed
+func (d *DiskGuard) Write(level logger.Level, headerLength int, data []byte) error {
+	if !d.guard.allow(level) {
+		return nil
+	}
+	return d.provider.Write(level, headerLength, data)
+}
+
+// Close stops checking and closes inner provider
+func (d *DiskGuard) Close() error {
+	close(d.quit)
+	<-d.done
+	return d.provider.Close()
+}
@@ -0,0 +1,201 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+)
+
+func TestDiskGuard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_diskguard_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// rotated files from oldest to newest, each file frees 100 bytes
+	now := time.Now()
+	var files []string
+	for i := 0; i < 4; i++ {
+		file := filepath.Join(dir, "app.2020040"+string(rune('1'+i))+".log")
+		assert.Nil(t, ioutil.WriteFile(file, []byte("x"), 0644))
+		assert.Nil(t, os.Chtimes(file, now, now.Add(time.Duration(i-10)*time.Hour)))
+		files = append(files, file)
+	}
+	// foreign files are older than all rotated files but never deleted
+	for _, name := range []string{"other.txt", "other.log"} {
+		file := filepath.Join(dir, name)
+		assert.Nil(t, ioutil.WriteFile(file, []byte("x"), 0644))
+		assert.Nil(t, os.Chtimes(file, now, now.Add(-time.Hour*24)))
+	}
+
+	var free int64
+	statfs := func(string) (diskUsage, error) {
+		infos, _ := ioutil.ReadDir(dir)
+		return diskUsage{freeBytes: free + int64(400-100*(len(infos)-1)), freeInodes: 1 << 20}, nil
+	}
+	buf := new(bytes.Buffer)
+	inner := NewConsoleWithWriter(`{"tostderrlevel":-2}`, buf, buf)
+	p, err := NewDiskGuard(inner, dir, "app.*.log", DiskGuardOpts{
+		Interval:      logger.Duration(time.Hour),
+		LowBytes:      1000,
+		CriticalBytes: 500,
+		DeleteOldest:  true,
+		Keep:          1,
+	})
+	assert.Nil(t, err)
+	defer p.Close()
+	alert := new(bytes.Buffer)
+	p.guard.alert = NewConsoleWithWriter("", alert, alert)
+	p.guard.statfs = statfs
+	check := func(current string) {
+		p.guard.lastCheck = time.Time{}
+		p.guard.check(time.Now(), current)
+	}
+	write := func() string {
+		buf.Reset()
+		for _, lv := range []logger.Level{logger.TRACE, logger.DEBUG, logger.INFO, logger.WARN, logger.ERROR, logger.FATAL} {
+			p.Write(lv, 0, []byte(lv.String()[:1]))
+		}
+		return buf.String()
+	}
+
+	free = 10000
+	check(files[3])
+	assert.Equal(t, "TDIWEF", write())
+
+	// low: deletes oldest files until enough space, but newest 1 and current kept
+	free = 700
+	check(files[3])
+	assert.Equal(t, "IWEF", write())
+	for i, file := range files {
+		_, err := os.Stat(file)
+		assert.Equal(t, i >= 2, err == nil, file)
+	}
+	assert.Equal(t, 1, strings.Count(alert.String(), "\n"))
+
+	// critical: warns only once
+	free = 0
+	check(files[3])
+	check(files[3])
+	assert.Equal(t, "EF", write())
+	assert.Equal(t, 1, strings.Count(alert.String(), "\n"))
+
+	// recovered
+	free = 10000
+	check(files[3])
+	assert.Equal(t, "TDIWEF", write())
+	free = 0
+	check(files[3])
+	assert.Equal(t, 2, strings.Count(alert.String(), "\n"))
+	for _, name := range []string{"other.txt", "other.log"} {
+		_, err = os.Stat(filepath.Join(dir, name))
+		assert.Nil(t, err, name)
+	}
+}
+
+func TestFileRotatedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_diskguard_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	other := fmt.Sprintf("%06d", pid+1)
+	self := fmt.Sprintf("%06d", pid)
+	for _, tc := range []struct {
+		opts      FileOpts
+		rotated   []string
+		unrelated []string
+	}{
+		{
+			opts:      FileOpts{Filename: "app", DailyAppend: true},
+			rotated:   []string{"app.20200401.log", "app.20200401.001.log", "app.20200401-1200.log"},
+			unrelated: []string{"other.log", "other.20200401.log", "app.log", "app.20200401.log.gz", "app.20200401-1200.000123.log"},
+		},
+		{
+			opts:      FileOpts{Filename: "app"},
+			rotated:   []string{"app.20200401-1200." + self + ".log", "app.20200401-1200." + self + ".002.log"},
+			unrelated: []string{"other.log", "app.20200401-1200." + other + ".log", "app.20200401.log"},
+		},
+		{
+			opts:      FileOpts{Pattern: "app-%Y%m%d-%H.log", DailyAppend: true},
+			rotated:   []string{"app-20200401-12.log", "app-20200401-12.001.log"},
+			unrelated: []string{"other.log", "app.log", "app-20200401.log", "web-20200401-12.log"},
+		},
+		{
+			opts:      FileOpts{Pattern: "app-20060102.log"},
+			rotated:   []string{"app-20200401." + self + ".log"},
+			unrelated: []string{"app-20200401.log", "app-20200401." + other + ".log"},
+		},
+	} {
+		opts := tc.opts
+		opts.Dir = dir
+		opts.setDefaults()
+		re := opts.rotatedFileRegexp()
+		for _, name := range tc.rotated {
+			assert.True(t, re.MatchString(name), "%s: %s", re, name)
+		}
+		for _, name := range tc.unrelated {
+			assert.False(t, re.MatchString(name), "%s: %s", re, name)
+		}
+	}
+
+	// the current file and the file of shared state are kept
+	for _, name := range []string{"app.20200401-1200.log", "app.20200402-1200.log", "app.20200403-1200.log", "other.log"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("x"), 0644))
+	}
+	opts := FileOpts{Dir: dir, Filename: "app", Shared: true}
+	opts.setDefaults()
+	g, err := newDiskGuard(DiskGuardOpts{})
+	assert.Nil(t, err)
+	g.add(dir, opts.rotatedFileRegexp().MatchString, nil)
+	files := g.rotatedFiles(filepath.Join(dir, "app.20200403-1200.log"), filepath.Join(dir, "app.20200402-1200.log"))
+	assert.Equal(t, []string{filepath.Join(dir, "app.20200401-1200.log")}, files)
+}
+
+func TestMultiFileDiskGuard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_diskguard_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	p, err := OpenMultiFile(MultiFileOpts{
+		RootDir:   dir,
+		Filename:  "app",
+		NoSymlink: true,
+		Banner:    "none",
+		DiskGuard: &DiskGuardOpts{Interval: logger.Duration(time.Hour), LowBytes: 1000, CriticalBytes: -1},
+	})
+	assert.Nil(t, err)
+	defer p.Close()
+	for _, lv := range []logger.Level{logger.TRACE, logger.DEBUG, logger.INFO, logger.WARN, logger.ERROR} {
+		assert.Nil(t, p.Write(lv, 0, []byte("x\n")))
+	}
+
+	// files in level directories on the same volume share a guard
+	files := p.opened()
+	assert.Equal(t, 5, len(files))
+	for _, f := range files[1:] {
+		assert.True(t, f.guard == files[0].guard)
+	}
+	alert := new(bytes.Buffer)
+	g := files[0].guard
+	g.alert = NewConsoleWithWriter("", alert, alert)
+	g.statfs = func(string) (diskUsage, error) { return diskUsage{freeBytes: 10, freeInodes: 1 << 20}, nil }
+	g.lastCheck = time.Time{}
+	for _, f := range files {
+		f.guard.check(time.Now())
+	}
+	assert.Equal(t, 1, strings.Count(alert.String(), "\n"), alert.String())
+	assert.Nil(t, p.Write(logger.DEBUG, 0, []byte("dropped\n")))
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("written\n")))
+	debug, info := p.files[logger.DEBUG].path, p.files[logger.INFO].path
+	assert.Nil(t, p.Close())
+	assert.Equal(t, "x\n", readFile(t, debug))
+	assert.Equal(t, "x\nwritten\n", readFile(t, info))
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mkideal/log/logger"
+)
+
+// entryFields returns context fields of e in their JSON representation whatever the
+// type of the context value is, e.g. numbers are float64, except that durations are
+// time.Duration. The snapshot of the context value is preferred to the formatted body,
+// nil is returned if e has no fields or fields are not an object.
+func entryFields(e logger.Entry) map[string]interface{} {
+	v := logger.Detailed(e).Value()
+	data := e.Body()
+	if v != nil {
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil
+		}
+	}
+	if len(data) == 0 || data[0] != '{' {
+		return nil
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	if v != nil {
+		for key := range m {
+			field, _ := logger.LookupField(v, key)
+			switch x := field.(type) {
+			case time.Duration:
+				m[key] = x
+			case logger.Duration:
+				m[key] = time.Duration(x)
+			}
+		}
+	}
+	return m
+}
+
+// fieldString formats value of a field for comparing with strings in options
+func fieldString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case time.Duration:
+		return strconv.FormatInt(int64(x), 10)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(x)
+	}
+}
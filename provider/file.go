@@ -2,16 +2,18 @@ package provider
 
 import (
 	"bufio"
-	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
+	"regexp"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/mkideal/log/logger"
+	"github.com/mkideal/log/metrics"
 )
 
 func init() {
@@ -20,19 +22,51 @@ func init() {
 
 var (
 	pid = os.Getpid()
+
+	errClosed = errors.New("provider closed")
 )
 
 // FileOpts represents options object of file provider
 type FileOpts struct {
-	Dir         string `json:"dir"`          // log directory(default: .)
-	Filename    string `json:"filename"`     // log filename(default: )
-	NoSymlink   bool   `json:"nosymlink"`    // doesn't create symlink to latest log file(default: false)
-	MaxSize     int    `json:"maxsize"`      // max bytes number of every log file(default: 64M)
-	DailyAppend bool   `json:"daily_append"` // append to existed file instead of creating a new file(default: true)
-	Suffix      string `json:"suffix"`       // filename suffix
-	DateFormat  string `json:"date_format"`  // date format string(default: %04d%02d%02d)
+	Dir         string          `json:"dir"`          // log directory(default: .)
+	Filename    string          `json:"filename"`     // log filename(default: )
+	NoSymlink   bool            `json:"nosymlink"`    // doesn't create symlink to latest log file(default: false)
+	MaxSize     int             `json:"maxsize"`      // max bytes number of every log file(default: 64M)
+	DailyAppend bool            `json:"daily_append"` // append to existed file instead of creating a new file, hour and minute are appended to the date if rotated more than daily(default: true)
+	Suffix      string          `json:"suffix"`       // filename suffix
+	DateFormat  string          `json:"date_format"`  // date format string(default: %04d%02d%02d)
+	Watch       bool            `json:"watch"`        // reopens the file if it's deleted or replaced and refreshes its size if truncated, checked every second(default: false)
+	Rotate      string          `json:"rotate"`       // rotation schedule: daily, hourly, none, 30m, 2h, 7d or cron spec like "0 */6 * * *"(default: daily)
+	Pattern     string          `json:"pattern"`      // filename pattern in strftime or Go layout, e.g. app-%Y%m%d-%H.log, overrides filename and date_format, it must tell rotation periods apart
+	TimeZone    string          `json:"timezone"`     // time zone of rotation boundaries and filenames, e.g. UTC, Asia/Shanghai(default: Local)
+	RetryMin    logger.Duration `json:"retry_min"`    // min interval of retrying to open file after failure(default: 1s)
+	RetryMax    logger.Duration `json:"retry_max"`    // max interval of retrying to open file, the interval doubles after every failure(default: 1m)
+	Fallback    string          `json:"fallback"`     // fallback output used while the file can't be opened: stderr, stdout or empty(default: )
+	DiskGuard   *DiskGuardOpts  `json:"disk_guard"`   // drops entries while free space of the volume is low, nil disables the guard(default: nil)
+
+	BufferSize    int             `json:"buffer_size"`    // size of write buffer(default: 16K)
+	FlushInterval logger.Duration `json:"flush_interval"` // interval of flushing buffer(default: 1s)
+	Sync          string          `json:"sync"`           // fsync policy: interval, never, always(every entry) or warn(entries of level WARN and above)(default: interval)
+	FlushOnError  bool            `json:"flush_on_error"` // flushes buffer immediately after ERROR, FATAL and PANIC entries written(default: false)
+
+	FileMode     FileMode `json:"file_mode"`     // permission of created files before umask(default: 0666)
+	DirMode      FileMode `json:"dir_mode"`      // permission of created directories before umask(default: 0755)
+	Owner        string   `json:"owner"`         // chowns created files and the directory to "user:group", "uid:gid", "user" or ":group"(default: )
+	Banner       string   `json:"banner"`        // text/template of banner written at the beginning of every file, fields are BannerInfo, none disables banner(default: "File opened at ...")
+	BannerFormat string   `json:"banner_format"` // banner format: text or json, json writes banner as an entry of JSON provider(default: text)
+	AppVersion   string   `json:"app_version"`   // app version available in banner as {{.Version}}
+
+	Shared bool `json:"shared"` // coordinates rotation with other processes writing the same files by flock, implies appending(default: false)
 }
 
+// fsync policies
+const (
+	SyncInterval = "interval" // syncs every flush interval
+	SyncNever    = "never"    // never syncs, the OS decides when data reaches the disk
+	SyncAlways   = "always"   // flushes and syncs after every entry
+	SyncWarn     = "warn"     // flushes and syncs after entries of level WARN and above
+)
+
 // NewFileOpts ...
 func NewFileOpts() FileOpts {
 	opts := FileOpts{}
@@ -41,6 +75,16 @@ func NewFileOpts() FileOpts {
 	return opts
 }
 
+// ParseFileOpts parses options of file provider from JSON or form string opts
+func ParseFileOpts(opts string) (FileOpts, error) {
+	config := NewFileOpts()
+	if err := logger.UnmarshalOpts(opts, &config); err != nil {
+		return config, err
+	}
+	config.setDefaults()
+	return config, config.validate()
+}
+
 func (opts *FileOpts) setDefaults() {
 	if opts.Dir == "" {
 		opts.Dir = "."
@@ -54,73 +98,465 @@ func (opts *FileOpts) setDefaults() {
 	if opts.Suffix == "" {
 		opts.Suffix = ".log"
 	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 1 << 14 // 16k
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = logger.Duration(time.Second)
+	}
+	if opts.Sync == "" {
+		opts.Sync = SyncInterval
+	}
+	if opts.FileMode == 0 {
+		opts.FileMode = 0666
+	}
+	if opts.DirMode == 0 {
+		opts.DirMode = 0755
+	}
+	if opts.BannerFormat == "" {
+		opts.BannerFormat = BannerText
+	}
+	if opts.RetryMin <= 0 {
+		opts.RetryMin = logger.Duration(time.Second)
+	}
+	if opts.RetryMax < opts.RetryMin {
+		opts.RetryMax = logger.Duration(time.Minute)
+		if opts.RetryMax < opts.RetryMin {
+			opts.RetryMax = opts.RetryMin
+		}
+	}
+}
+
+func (opts *FileOpts) validate() error {
+	loc, err := opts.location()
+	if err != nil {
+		return err
+	}
+	s, err := parseSchedule(opts.Rotate, loc)
+	if err != nil {
+		return err
+	}
+	if _, err := opts.periodClock(s, loc); err != nil {
+		return err
+	}
+	if _, err = newFallback(opts.Fallback); err != nil {
+		return err
+	}
+	switch opts.Sync {
+	case SyncInterval, SyncNever, SyncAlways, SyncWarn:
+	default:
+		return fmt.Errorf("unsupported sync policy %q", opts.Sync)
+	}
+	if opts.Shared && !flockSupported {
+		return errFlockUnsupported
+	}
+	switch opts.BannerFormat {
+	case BannerText, BannerJSON:
+	default:
+		return fmt.Errorf("unsupported banner format %q", opts.BannerFormat)
+	}
+	if _, err := parseBanner(opts.Banner); err != nil {
+		return err
+	}
+	if _, _, err := parseOwner(opts.Owner); err != nil {
+		return err
+	}
+	if opts.DiskGuard != nil {
+		_, err = newDiskGuard(*opts.DiskGuard)
+	}
+	return err
+}
+
+// rotatedFileRegexp returns the regexp matching names of files which File creates by
+// the options, it mirrors filename and create. Files named with pid of other processes
+// are excluded since they may be still written.
+func (opts *FileOpts) rotatedFileRegexp() *regexp.Regexp {
+	var name, suffix string
+	if opts.Pattern != "" {
+		if suffix = filepath.Ext(opts.Pattern); suffix == "" {
+			suffix = opts.Suffix
+		}
+		name = patternRegexp(strings.TrimSuffix(opts.Pattern, suffix))
+		if !opts.DailyAppend && !opts.Shared {
+			name += regexp.QuoteMeta(fmt.Sprintf(".%06d", pid))
+		}
+	} else {
+		suffix = opts.Suffix
+		if opts.Filename != "" {
+			name = regexp.QuoteMeta(opts.Filename + ".")
+		}
+		name += dateFormatRegexp(opts.DateFormat)
+		if opts.DailyAppend {
+			name += `(-\d{4})?`
+		} else {
+			name += `-\d{4}`
+			if !opts.Shared {
+				name += regexp.QuoteMeta(fmt.Sprintf(".%06d", pid))
+			}
+		}
+	}
+	return regexp.MustCompile("^" + name + `(\.\d{3})?` + regexp.QuoteMeta(suffix) + "$")
+}
+
+// dateFormatRegexp converts verbs of the printf format to regexps
+func dateFormatRegexp(format string) string {
+	var buf strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			buf.WriteString(regexp.QuoteMeta(format[i : i+1]))
+			continue
+		}
+		i++
+		for i+1 < len(format) && strings.IndexByte("+-# 0123456789.", format[i]) >= 0 {
+			i++
+		}
+		switch format[i] {
+		case '%':
+			buf.WriteByte('%')
+		case 'd':
+			buf.WriteString(`\d+`)
+		default:
+			buf.WriteString(`\w+`)
+		}
+	}
+	return buf.String()
 }
 
-// File is a provider which writes logs to file
+// patternRegexp converts a strftime pattern or Go layout to a regexp, see formatFilename
+func patternRegexp(pattern string) string {
+	var buf strings.Builder
+	if !strings.ContainsRune(pattern, '%') {
+		for i := 0; i < len(pattern); {
+			switch {
+			case pattern[i] >= '0' && pattern[i] <= '9':
+				for i < len(pattern) && pattern[i] >= '0' && pattern[i] <= '9' {
+					i++
+				}
+				buf.WriteString(`\d+`)
+			case strings.HasPrefix(pattern[i:], "Jan"), strings.HasPrefix(pattern[i:], "Mon"):
+				i += 3
+				for _, long := range []string{"uary", "day"} {
+					if strings.HasPrefix(pattern[i:], long) {
+						i += len(long)
+					}
+				}
+				buf.WriteString(`[A-Za-z]+`)
+			default:
+				buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+				i++
+			}
+		}
+		return buf.String()
+	}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y', 'y', 'm', 'd', 'H', 'M', 'S', 'j', 's':
+			buf.WriteString(`\d+`)
+		case 'b', 'a':
+			buf.WriteString(`[A-Za-z]+`)
+		case '%':
+			buf.WriteByte('%')
+		default:
+			buf.WriteString(regexp.QuoteMeta(pattern[i-1 : i+1]))
+		}
+	}
+	return buf.String()
+}
+
+// location returns time zone of the options
+func (opts *FileOpts) location() (*time.Location, error) {
+	switch opts.TimeZone {
+	case "", "Local":
+		return time.Local, nil
+	}
+	return time.LoadLocation(opts.TimeZone)
+}
+
+// File is a provider which writes logs to file.
+//
+// If the file can't be opened, File retries with backoff while writing, and writes
+// entries to the fallback provider if any in the meantime. Health reports its state.
 type File struct {
-	config           FileOpts
-	currentSize      int
-	createdTime      time.Time
-	fileIndex        int
-	onceCreateLogDir sync.Once
+	config       FileOpts
+	currentSize  int
+	createdTime  time.Time
+	fileIndex    int
+	loc          *time.Location
+	schedule     schedule
+	nextRotation time.Time
+	clock        bool // appends hour and minute to names of daily appended files, see periodClock
+	health       health
+	guard        *diskGuard // nil if disk-space guard disabled
+	lockFile     *os.File   // lock file of shared mode, nil if not opened
+	pending      int        // pendingNone, pendingFlush or pendingSync
+	banner       *template.Template
+	uid, gid     int // -1 if unspecified
 
-	mu      sync.Mutex
-	writer  *bufio.Writer
-	file    *os.File
-	written bool
+	mu         sync.Mutex
+	writer     *bufio.Writer // nil if no file opened
+	file       *os.File
+	path       string // path of current file
+	written    bool
+	closed     bool
+	retryAt    time.Time     // time of next retrying to open file
+	retryDelay time.Duration // delay of next retrying
+	fallback   logger.Provider
+
+	quit      chan struct{}
+	closeOnce sync.Once
 }
 
-// NewFile creates file provider
+// NewFile creates file provider, it reports errors of opts by Health and writes
+// entries to stderr if opts are invalid. Use OpenFile to get errors directly.
 func NewFile(opts string) logger.Provider {
-	config := NewFileOpts()
-	logger.UnmarshalOpts(opts, &config)
-	config.setDefaults()
+	config, err := ParseFileOpts(opts)
+	if err != nil {
+		return newFailedProvider(err)
+	}
 	return newFile(config)
 }
 
+// OpenFile creates file provider and opens the log file
+func OpenFile(config FileOpts) (*File, error) {
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	p := newFile(config)
+	if h := p.Health(); h.State == Failed {
+		p.Close()
+		return nil, h.LastError
+	}
+	return p, nil
+}
+
 func newFile(config FileOpts) *File {
+	return newGuardedFile(config, nil)
+}
+
+// newGuardedFile creates file provider whose disk-space guard is shared by guards,
+// the file has its own guard if guards is nil
+func newGuardedFile(config FileOpts, guards *diskGuards) *File {
+	if config.FileMode == 0 {
+		config.FileMode = 0666
+	}
+	if config.DirMode == 0 {
+		config.DirMode = 0755
+	}
 	p := &File{
-		config:    config,
-		fileIndex: -1,
+		config:     config,
+		fileIndex:  -1,
+		retryDelay: time.Duration(config.RetryMin),
+		quit:       make(chan struct{}),
+	}
+	var err error
+	if p.loc, err = config.location(); err != nil {
+		p.loc = time.Local
+	}
+	if p.schedule, err = parseSchedule(config.Rotate, p.loc); err != nil {
+		p.schedule, _ = parseSchedule("", p.loc)
 	}
+	p.clock, _ = config.periodClock(p.schedule, p.loc)
+	p.fallback, _ = newFallback(config.Fallback)
+	p.banner, _ = parseBanner(config.Banner)
+	p.uid, p.gid, _ = parseOwner(config.Owner)
+	p.mu.Lock()
 	p.rotate(time.Now())
-	go func(f *File) {
-		for range time.Tick(time.Second) {
-			f.mu.Lock()
-			if f.written {
-				f.writer.Flush()
-				f.file.Sync()
-				f.written = false
-			}
-			f.mu.Unlock()
+	p.mu.Unlock()
+	if config.DiskGuard != nil {
+		// the guard is got after rotating since the volume is known after the directory created
+		if guards != nil {
+			p.guard, _ = guards.get(config.Dir)
+		} else {
+			p.guard, _ = newDiskGuard(*config.DiskGuard)
 		}
-	}(p)
+		if p.guard != nil {
+			p.guard.add(config.Dir, config.rotatedFileRegexp().MatchString, p)
+			p.guard.check(time.Now())
+		}
+	}
+	go p.run()
 	return p
 }
 
+// run flushes the file every flush interval, and checks the file every second
+func (p *File) run() {
+	interval := time.Duration(p.config.FlushInterval)
+	if interval <= 0 {
+		interval = time.Second
+	}
+	flushTicker := time.NewTicker(interval)
+	defer flushTicker.Stop()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.quit:
+			return
+		case <-flushTicker.C:
+			p.mu.Lock()
+			if p.written && p.writer != nil {
+				p.flush(p.config.Sync != SyncNever)
+			}
+			p.mu.Unlock()
+		case now := <-ticker.C:
+			if p.guard != nil {
+				p.guard.check(now)
+			}
+			p.mu.Lock()
+			if p.config.Shared && !p.closed {
+				p.checkShared(now)
+			}
+			if p.config.Watch && p.path != "" && !p.closed {
+				if p.file == nil || p.replaced() {
+					p.reopen()
+				} else {
+					p.refreshSize()
+				}
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// currentFiles returns files being written which the disk-space guard never deletes,
+// in shared mode the file of the shared state may be written by other processes
+func (p *File) currentFiles() []string {
+	files := []string{p.path}
+	if p.config.Shared {
+		files = append(files, p.readState().path)
+	}
+	return files
+}
+
+// flush flushes buffer to the file, and syncs the file if sync is true
+func (p *File) flush(sync bool) {
+	var errs errorList
+	errs.tryPush(p.writer.Flush())
+	if sync {
+		errs.tryPush(p.file.Sync())
+	}
+	if err := errs.err(); err != nil {
+		p.health.set(Degraded, err)
+	}
+	p.written = false
+}
+
+// SetFallback sets the provider used while the file can't be opened
+func (p *File) SetFallback(fallback logger.Provider) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fallback = fallback
+}
+
+// Health implements HealthReporter interface
+func (p *File) Health() Health { return p.health.get() }
+
 // Write writes log to file
 func (p *File) Write(level logger.Level, headerLength int, data []byte) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	err := p.write(time.Now(), level, headerLength, data)
+	p.commit()
+	return err
+}
+
+// WriteBatch implements logger.BatchProvider interface, the buffer is flushed and synced
+// at most once for all entries
+func (p *File) WriteBatch(entries []logger.Entry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var (
+		errs errorList
+		now  = time.Now()
+	)
+	for _, e := range entries {
+		d := logger.Detailed(e)
+		errs.tryPush(p.write(now, d.Level(), d.HeaderLength(), d.Bytes()))
+	}
+	p.commit()
+	return errs.err()
+}
 
-	if p.writer == nil {
-		return errNilWriter
+// write writes data to buffer, flushing and syncing required by durability options
+// are deferred to commit
+func (p *File) write(now time.Time, level logger.Level, headerLength int, data []byte) error {
+	if p.guard != nil && !p.guard.allow(level) {
+		return nil
 	}
-	now := time.Now()
-	if !isSameDay(now, p.createdTime) {
+	if p.closed {
+		return errClosed
+	}
+	if p.writer == nil {
+		if now.Before(p.retryAt) {
+			return p.writeFallback(level, headerLength, data, p.health.get().LastError)
+		}
+		if err := p.rotate(now); err != nil {
+			return p.writeFallback(level, headerLength, data, err)
+		}
+	} else if !now.Before(p.nextRotation) {
 		if err := p.rotate(now); err != nil {
-			return err
+			return p.writeFallback(level, headerLength, data, err)
 		}
 	}
+	if p.config.Shared && p.writer.Available() < len(data) {
+		// flushes whole entries only, so entries of processes are not interleaved
+		p.writer.Flush()
+	}
 	n, err := p.writer.Write(data)
 	p.written = true
 	p.currentSize += n
+	if err != nil {
+		p.health.set(Degraded, err)
+		return err
+	}
+	switch {
+	case p.config.Sync == SyncAlways, p.config.Sync == SyncWarn && !level.MoreVerboseThan(logger.WARN):
+		p.pending = pendingSync
+	case p.config.FlushOnError && !level.MoreVerboseThan(logger.ERROR):
+		if p.pending < pendingFlush {
+			p.pending = pendingFlush
+		}
+	}
 	if p.currentSize >= p.config.MaxSize {
+		p.commit()
 		p.rotate(now)
 	}
-	return err
+	return nil
+}
+
+// pending operations of commit
+const (
+	pendingNone = iota
+	pendingFlush
+	pendingSync
+)
+
+// commit flushes or syncs the file if required by entries written
+func (p *File) commit() {
+	if p.pending != pendingNone && p.writer != nil {
+		p.flush(p.pending == pendingSync)
+	}
+	p.pending = pendingNone
+}
+
+// writeFallback writes data to fallback provider, err is returned if no fallback provider
+func (p *File) writeFallback(level logger.Level, headerLength int, data []byte, err error) error {
+	if p.fallback == nil {
+		if err == nil {
+			err = errNilWriter
+		}
+		return err
+	}
+	return p.fallback.Write(level, headerLength, data)
 }
 
+// closeCurrent flushes and closes current file, it's safe to be called if no file opened
 func (p *File) closeCurrent() error {
 	var err errorList
 	if p.writer != nil {
@@ -129,98 +565,286 @@ func (p *File) closeCurrent() error {
 		err.tryPush(p.file.Close())
 		p.written = false
 	}
+	p.writer = nil
+	p.file = nil
 	p.currentSize = 0
 	return err.err()
 }
 
+// Reopen flushes and closes current log file, and then reopens the file by the same path.
+// It should be called after the file moved by external tools like logrotate.
+func (p *File) Reopen() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errClosed
+	}
+	return p.reopen()
+}
+
+func (p *File) reopen() error {
+	if p.path == "" {
+		return errNilWriter
+	}
+	closeErr := p.closeCurrent()
+	file, err := os.OpenFile(p.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, os.FileMode(p.config.FileMode))
+	if err != nil {
+		p.failed(time.Now(), err)
+		return err
+	}
+	p.opened(file)
+	if fi, err := file.Stat(); err == nil {
+		p.currentSize = int(fi.Size())
+	}
+
+	var errs errorList
+	errs.tryPush(closeErr)
+	errs.tryPush(p.chown(p.path))
+	if err := errs.err(); err != nil {
+		p.health.set(Degraded, err)
+		return err
+	}
+	return nil
+}
+
+// replaced reports whether the current file was deleted or replaced by another file
+func (p *File) replaced() bool {
+	fi, err := p.file.Stat()
+	if err != nil {
+		return false
+	}
+	pathInfo, err := os.Stat(p.path)
+	return err != nil || !os.SameFile(fi, pathInfo)
+}
+
+// refreshSize refreshes the size of current file, e.g. it's truncated by copytruncate
+// of logrotate
+func (p *File) refreshSize() {
+	if fi, err := p.file.Stat(); err == nil {
+		p.currentSize = int(fi.Size()) + p.writer.Buffered()
+	}
+}
+
 // Close closes current log file
 func (p *File) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.quit)
+		if p.guard != nil {
+			p.guard.remove(p)
+		}
+	})
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.closed = true
+	if p.lockFile != nil {
+		p.lockFile.Close()
+		p.lockFile = nil
+	}
 	return p.closeCurrent()
 }
 
+// opened sets file as current file
+func (p *File) opened(file *os.File) {
+	p.file = file
+	p.writer = bufio.NewWriterSize(p.file, p.config.BufferSize)
+	p.retryAt = time.Time{}
+	p.retryDelay = time.Duration(p.config.RetryMin)
+	p.health.set(Healthy, nil)
+}
+
+// failed records the error of opening file and schedules next retrying
+func (p *File) failed(now time.Time, err error) {
+	p.health.set(Failed, err)
+	p.retryAt = now.Add(p.retryDelay)
+	p.retryDelay *= 2
+	if max := time.Duration(p.config.RetryMax); p.retryDelay > max {
+		p.retryDelay = max
+	}
+}
+
 func (p *File) rotate(now time.Time) error {
-	p.closeCurrent()
-	if isSameDay(now, p.createdTime) {
-		p.fileIndex = (p.fileIndex + 1) % 1000
-	} else {
+	if p.config.Shared {
+		return p.rotateShared(now)
+	}
+	if !now.Before(p.nextRotation) {
 		p.fileIndex = 0
+		p.nextRotation = p.schedule.next(now)
+	} else if p.writer != nil {
+		p.fileIndex = (p.fileIndex + 1) % 1000
 	}
-	p.createdTime = now
+	return p.openNext(now)
+}
 
-	var err error
-	p.file, err = p.create()
+// openNext closes current file and creates the file of current index
+func (p *File) openNext(now time.Time) error {
+	if p.writer != nil {
+		metrics.Rotations.With(p.config.Dir).Inc()
+	}
+	closeErr := p.closeCurrent()
+	p.createdTime = now.In(p.loc)
+
+	file, symlinkErr, err := p.create()
 	if err != nil {
+		p.failed(now, err)
 		return err
 	}
+	p.opened(file)
 
-	p.writer = bufio.NewWriterSize(p.file, 1<<14) // 16k
-	var buf bytes.Buffer
-	fmt.Fprintf(&buf, "File opened at: %s\n", now.Format("2006/01/02 15:04:05"))
-	fmt.Fprintf(&buf, "Built with %s %s for %s/%s\n", runtime.Compiler, runtime.Version(), runtime.GOOS, runtime.GOARCH)
-	n, err := p.file.Write(buf.Bytes())
+	n, err := p.writeBanner(now)
 	p.currentSize += n
-	p.writer.Flush()
-	p.file.Sync()
-	return err
+
+	var errs errorList
+	errs.tryPush(closeErr)
+	errs.tryPush(symlinkErr)
+	errs.tryPush(err)
+	if err := errs.err(); err != nil {
+		p.health.set(Degraded, err)
+	}
+	return nil
 }
 
-func (p *File) create() (*os.File, error) {
-	p.onceCreateLogDir.Do(p.createDir)
+// writeBanner writes banner to current file
+func (p *File) writeBanner(now time.Time) (int, error) {
+	if p.config.Banner == "none" {
+		return 0, nil
+	}
+	b, err := renderBanner(p.banner, p.config.BannerFormat, newBannerInfo(now, p.path, p.config.AppVersion))
+	if err != nil {
+		return 0, err
+	}
+	return p.file.Write(b)
+}
 
-	// make filename
-	var (
-		y, m, d = p.createdTime.Date()
-		name    string
-		prefix  = p.config.Filename
-		date    = fmt.Sprintf(p.config.DateFormat, y, m, d)
-	)
-	if p.config.Filename != "" {
-		prefix += "."
+// chown changes owner of name if owner configured
+func (p *File) chown(name string) error {
+	if p.uid < 0 && p.gid < 0 {
+		return nil
 	}
-	if p.config.DailyAppend {
-		name = fmt.Sprintf("%s%s", prefix, date)
-	} else {
-		H, M, _ := p.createdTime.Clock()
-		name = fmt.Sprintf("%s%s-%02d%02d.%06d", prefix, date, H, M, pid)
+	return os.Chown(name, p.uid, p.gid)
+}
+
+// create creates the log file, error of creating symlink or changing owner is returned separately
+func (p *File) create() (f *os.File, symlinkErr, err error) {
+	if err = os.MkdirAll(p.config.Dir, os.FileMode(p.config.DirMode)); err != nil {
+		return nil, nil, err
 	}
+	var errs errorList
+	errs.tryPush(p.chown(p.config.Dir))
+
+	// make filename
+	name, suffix := p.filename()
 	if p.fileIndex > 0 {
 		name = fmt.Sprintf("%s.%03d", name, p.fileIndex)
 	}
-	if !strings.HasSuffix(name, p.config.Suffix) {
-		name += p.config.Suffix
+	if !strings.HasSuffix(name, suffix) {
+		name += suffix
 	}
 
 	// create file
-	var (
-		fullname = filepath.Join(p.config.Dir, name)
-		f        *os.File
-		err      error
-	)
-	if p.config.DailyAppend {
-		f, err = os.OpenFile(fullname, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	fullname := filepath.Join(p.config.Dir, name)
+	if p.config.DailyAppend || p.config.Shared {
+		f, err = os.OpenFile(fullname, os.O_RDWR|os.O_CREATE|os.O_APPEND, os.FileMode(p.config.FileMode))
 	} else {
-		f, err = os.OpenFile(fullname, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+		f, err = os.OpenFile(fullname, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(p.config.FileMode))
 	}
-	if err == nil && !p.config.NoSymlink {
+	if err != nil {
+		return nil, nil, err
+	}
+	errs.tryPush(p.chown(fullname))
+	p.path = fullname
+	if !p.config.NoSymlink {
 		tmp := p.config.Filename
 		if tmp == "" {
 			tmp = filepath.Base(os.Args[0])
 		}
-		symlink := filepath.Join(p.config.Dir, tmp+p.config.Suffix)
-		os.Remove(symlink)
-		os.Symlink(name, symlink)
+		symlink := filepath.Join(p.config.Dir, tmp+suffix)
+		// replaces the symlink atomically by renaming a temporary symlink
+		tmp = fmt.Sprintf("%s.%d", symlink, pid)
+		if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+			errs.tryPush(err)
+		} else if err := os.Symlink(name, tmp); err != nil {
+			errs.tryPush(err)
+		} else if err := os.Rename(tmp, symlink); err != nil {
+			os.Remove(tmp)
+			errs.tryPush(err)
+		}
 	}
-	return f, err
+	return f, errs.err(), nil
 }
 
-func (p *File) createDir() {
-	os.MkdirAll(p.config.Dir, 0755)
+// filename returns name of current file without index, and the suffix
+func (p *File) filename() (name, suffix string) {
+	return p.config.filename(p.createdTime, p.clock)
 }
 
-func isSameDay(t1, t2 time.Time) bool {
-	y1, m1, d1 := t1.Date()
-	y2, m2, d2 := t2.Date()
-	return y1 == y2 && m1 == m2 && d1 == d2
+// filename returns name of the file created at t without index, and the suffix.
+// If clock is true, hour and minute are appended to the date of daily appended files.
+func (opts *FileOpts) filename(t time.Time, clock bool) (name, suffix string) {
+	if opts.Pattern != "" {
+		name = formatFilename(opts.Pattern, t)
+		if suffix = filepath.Ext(name); suffix == "" {
+			suffix = opts.Suffix
+		}
+		name = strings.TrimSuffix(name, suffix)
+		if !opts.DailyAppend && !opts.Shared {
+			name = fmt.Sprintf("%s.%06d", name, pid)
+		}
+		return name, suffix
+	}
+	var (
+		y, m, d = t.Date()
+		prefix  = opts.Filename
+		date    = fmt.Sprintf(opts.DateFormat, y, m, d)
+	)
+	if opts.Filename != "" {
+		prefix += "."
+	}
+	H, M, _ := t.Clock()
+	if opts.DailyAppend {
+		if clock {
+			return fmt.Sprintf("%s%s-%02d%02d", prefix, date, H, M), opts.Suffix
+		}
+		return fmt.Sprintf("%s%s", prefix, date), opts.Suffix
+	}
+	if opts.Shared {
+		return fmt.Sprintf("%s%s-%02d%02d", prefix, date, H, M), opts.Suffix
+	}
+	return fmt.Sprintf("%s%s-%02d%02d.%06d", prefix, date, H, M, pid), opts.Suffix
+}
+
+// periodClock reports whether hour and minute should be appended to names of daily
+// appended files since the date format can't tell rotation periods apart, e.g. hourly
+// rotation with the default date format. An error is returned if names of consecutive
+// periods are the same anyway, e.g. pattern app-%Y%m%d.log with hourly rotation, which
+// would truncate or append to the file of the previous period.
+func (opts *FileOpts) periodClock(s schedule, loc *time.Location) (bool, error) {
+	if opts.distinctPeriods(s, loc, false) {
+		return false, nil
+	}
+	if opts.Pattern != "" {
+		return false, fmt.Errorf("pattern %q can't tell rotation periods %q apart", opts.Pattern, opts.Rotate)
+	}
+	if opts.DailyAppend && opts.distinctPeriods(s, loc, true) {
+		return true, nil
+	}
+	return false, fmt.Errorf("date format %q can't tell rotation periods %q apart", opts.DateFormat, opts.Rotate)
+}
+
+// distinctPeriods reports whether files of consecutive rotation periods have distinct names,
+// periods of the schedule are sampled from 2020-01-01
+func (opts *FileOpts) distinctPeriods(s schedule, loc *time.Location, clock bool) bool {
+	if _, ok := s.(noSchedule); ok {
+		return true
+	}
+	t := s.next(time.Date(2020, 1, 1, 0, 0, 0, 0, loc))
+	for i := 0; i < 64; i++ {
+		next := s.next(t)
+		name, _ := opts.filename(t, clock)
+		nextName, _ := opts.filename(next, clock)
+		if name == nextName {
+			return false
+		}
+		t = next
+	}
+	return true
 }
@@ -0,0 +1,508 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+)
+
+func readFile(t *testing.T, filename string) string {
+	data, err := ioutil.ReadFile(filename)
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func TestFileReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_file_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	p := NewFile(`{"dir":"` + filepath.ToSlash(dir) + `","filename":"app","nosymlink":true}`).(*File)
+	defer p.Close()
+	path := p.path
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("before\n")))
+
+	// logrotate: moves the file and then signals
+	assert.Nil(t, os.Rename(path, path+".1"))
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("moved\n")))
+	assert.Nil(t, p.Reopen())
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("after\n")))
+	assert.Nil(t, p.Close())
+
+	rotated := readFile(t, path+".1")
+	assert.True(t, strings.HasSuffix(rotated, "before\nmoved\n"), rotated)
+	assert.Equal(t, "after\n", readFile(t, path))
+}
+
+func TestFileReopenCloseError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_file_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	p := NewFile(`{"dir":"` + filepath.ToSlash(dir) + `","filename":"app","nosymlink":true}`).(*File)
+	defer p.Close()
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("before\n")))
+
+	// closing current file fails because it's closed underneath
+	assert.Nil(t, p.file.Close())
+	assert.Error(t, p.Reopen())
+	h := p.Health()
+	assert.Equal(t, Degraded, h.State)
+	assert.Error(t, h.LastError)
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("after\n")))
+}
+
+func TestFileWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_file_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	p := NewFile(`{"dir":"` + filepath.ToSlash(dir) + `","filename":"app","nosymlink":true,"watch":true}`).(*File)
+	defer p.Close()
+	path := p.path
+	assert.Nil(t, os.Remove(path))
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("recreated\n")))
+	assert.Nil(t, p.Close())
+	assert.Equal(t, "recreated\n", readFile(t, path))
+}
+
+func TestFileWatchTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_file_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	p := NewFile(`{"dir":"` + filepath.ToSlash(dir) + `","filename":"app","nosymlink":true,"watch":true,"banner":"none","sync":"always"}`).(*File)
+	defer p.Close()
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("before\n")))
+
+	size := func() int {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.currentSize
+	}
+	assert.Equal(t, len("before\n"), size())
+
+	// logrotate: copytruncate
+	assert.Nil(t, os.Truncate(p.path, 0))
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) && size() != 0 {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Equal(t, 0, size())
+}
+
+func TestMultiFileReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_multifile_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	p := NewMultiFile(`{"rootdir":"` + filepath.ToSlash(dir) + `","filename":"app","nosymlink":true}`).(*MultiFile)
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("info\n")))
+	assert.Nil(t, p.Write(logger.ERROR, 0, []byte("error\n")))
+	infoPath, errorPath := p.files[logger.INFO].path, p.files[logger.ERROR].path
+	assert.Nil(t, os.Rename(infoPath, infoPath+".1"))
+	assert.Nil(t, os.Rename(errorPath, errorPath+".1"))
+
+	assert.Nil(t, NewMixProvider(p).(logger.Reopener).Reopen())
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("info2\n")))
+	assert.Nil(t, p.Write(logger.FATAL, 0, []byte("fatal\n")))
+	p.files[logger.INFO].Close()
+	p.files[logger.ERROR].Close()
+	assert.Equal(t, "info2\n", readFile(t, infoPath))
+	assert.Equal(t, "fatal\n", readFile(t, errorPath))
+}
+
+func TestMultiFileConcurrentHealth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_multifile_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// files are created by the writer while health is polled and files are reopened
+	p := NewMultiFile(`{"rootdir":"` + filepath.ToSlash(dir) + `","filename":"app","nosymlink":true}`).(*MultiFile)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, level := range []logger.Level{logger.TRACE, logger.DEBUG, logger.INFO, logger.WARN, logger.ERROR, logger.FATAL} {
+			assert.Nil(t, p.Write(level, 0, []byte("x\n")))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, Healthy, p.Health().State)
+		assert.Nil(t, p.Reopen())
+	}
+	<-done
+	assert.Nil(t, p.Close())
+}
+
+func TestFileOpts(t *testing.T) {
+	_, err := ParseFileOpts(`{"timezone":"Nowhere/City"}`)
+	assert.Error(t, err)
+	_, err = ParseFileOpts(`{"rotate":"1w"}`)
+	assert.Error(t, err)
+	_, err = ParseFileOpts(`{"fallback":"syslog"}`)
+	assert.Error(t, err)
+	_, err = ParseFileOpts(`{"maxsize":"big"}`)
+	assert.Error(t, err)
+	opts, err := ParseFileOpts(`{"retry_min":"10ms","retry_max":2}`)
+	assert.Nil(t, err)
+	assert.Equal(t, logger.Duration(10*time.Millisecond), opts.RetryMin)
+	assert.Equal(t, logger.Duration(2*time.Second), opts.RetryMax)
+
+	p := NewFile(`{"rotate":"1w"}`)
+	h := p.(HealthReporter).Health()
+	assert.Equal(t, Failed, h.State)
+	assert.Error(t, h.LastError)
+}
+
+func TestFileFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_file_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// log directory can't be created because a regular file exists
+	logdir := filepath.Join(dir, "logs")
+	assert.Nil(t, ioutil.WriteFile(logdir, nil, 0644))
+	_, err = OpenFile(FileOpts{Dir: logdir})
+	assert.Error(t, err)
+
+	p := newFile(FileOpts{Dir: logdir, Filename: "app", Suffix: ".log", MaxSize: 1 << 20, DailyAppend: true, RetryMin: logger.Duration(10 * time.Millisecond), RetryMax: logger.Duration(20 * time.Millisecond)})
+	defer p.Close()
+	assert.Equal(t, Failed, p.Health().State)
+	assert.Error(t, p.Write(logger.INFO, 0, []byte("lost\n")))
+
+	fallback := new(bytes.Buffer)
+	p.SetFallback(NewConsoleWithWriter("", fallback, fallback))
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("fallback\n")))
+	assert.Equal(t, "fallback\n", fallback.String())
+
+	// recovers after the obstacle removed
+	assert.Nil(t, os.Remove(logdir))
+	time.Sleep(30 * time.Millisecond)
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("recovered\n")))
+	assert.Equal(t, Healthy, p.Health().State)
+	assert.Equal(t, "fallback\n", fallback.String())
+	assert.Nil(t, p.Close())
+	assert.True(t, strings.HasSuffix(readFile(t, p.path), "recovered\n"))
+	assert.Equal(t, errClosed, p.Write(logger.INFO, 0, []byte("closed\n")))
+}
+
+func TestFileDegraded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_file_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// symlink can't be created because a non-empty directory exists
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "app.log", "x"), 0755))
+	p, err := OpenFile(FileOpts{Dir: dir, Filename: "app"})
+	assert.Nil(t, err)
+	defer p.Close()
+	h := p.Health()
+	assert.Equal(t, Degraded, h.State)
+	assert.Error(t, h.LastError)
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("ok\n")))
+}
+
+func TestFileDurability(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_file_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		opts    string
+		level   logger.Level
+		flushed bool
+	}{
+		{`"sync":"interval"`, logger.ERROR, false},
+		{`"sync":"always"`, logger.DEBUG, true},
+		{`"sync":"warn"`, logger.INFO, false},
+		{`"sync":"warn"`, logger.WARN, true},
+		{`"sync":"never","flush_on_error":true`, logger.WARN, false},
+		{`"sync":"never","flush_on_error":true`, logger.ERROR, true},
+		{`"flush_on_error":true`, logger.FATAL, true},
+	} {
+		p := NewFile(`{"dir":"` + filepath.ToSlash(dir) + `","filename":"app","nosymlink":true,"flush_interval":"1h",` + tc.opts + `}`).(*File)
+		assert.Nil(t, p.Write(tc.level, 0, []byte("hello\n")))
+		content := readFile(t, p.path)
+		assert.Equal(t, tc.flushed, strings.HasSuffix(content, "hello\n"), "%s: level %v", tc.opts, tc.level)
+		assert.Nil(t, p.Close())
+		assert.Nil(t, os.Remove(p.path))
+	}
+
+	_, err = ParseFileOpts(`{"sync":"sometimes"}`)
+	assert.NotNil(t, err)
+}
+
+func BenchmarkFileWrite(b *testing.B) {
+	dir, err := ioutil.TempDir("", "log_file_bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := []byte("2006-01-02 15:04:05.000 [INFO] (file.go:100) benchmark of writing file with durability options\n")
+	for _, mode := range []struct {
+		name string
+		opts string
+	}{
+		{"never", `"sync":"never"`},
+		{"interval", `"sync":"interval"`},
+		{"interval-1M-buffer", `"sync":"interval","buffer_size":1048576`},
+		{"flush-on-error", `"sync":"interval","flush_on_error":true`},
+		{"warn", `"sync":"warn"`},
+		{"always", `"sync":"always"`},
+	} {
+		mode := mode
+		b.Run(mode.name, func(b *testing.B) {
+			p := NewFile(`{"dir":"` + filepath.ToSlash(dir) + `","filename":"bench","nosymlink":true,` + mode.opts + `}`).(*File)
+			defer p.Close()
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// one of every 100 entries is an error
+				level := logger.INFO
+				if i%100 == 0 {
+					level = logger.ERROR
+				}
+				if err := p.Write(level, 0, data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestFileBanner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_file_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	newFile := func(opts string) *File {
+		return NewFile(`{"dir":"` + filepath.ToSlash(dir) + `","filename":"app","nosymlink":true,` + opts + `}`).(*File)
+	}
+
+	// default banner
+	p := newFile(`"suffix":".default"`)
+	assert.Nil(t, p.Close())
+	assert.True(t, strings.HasPrefix(readFile(t, p.path), "File opened at: "))
+
+	// templated banner
+	p = newFile(`"suffix":".tmpl","app_version":"v1.2.3","banner":"{{.App}} {{.Version}} pid={{.Pid}}"`)
+	assert.Nil(t, p.Close())
+	assert.Equal(t, filepath.Base(os.Args[0])+" v1.2.3 pid="+strconv.Itoa(os.Getpid())+"\n", readFile(t, p.path))
+
+	// disabled banner
+	p = newFile(`"suffix":".none","banner":"none"`)
+	assert.Nil(t, p.Write(logger.INFO, 0, []byte("hello\n")))
+	assert.Nil(t, p.Close())
+	assert.Equal(t, "hello\n", readFile(t, p.path))
+
+	// structured banner
+	p = newFile(`"suffix":".json","banner_format":"json","app_version":"v1.2.3"`)
+	assert.Nil(t, p.Close())
+	var banner struct {
+		Level string
+		Msg   string
+		Data  BannerInfo
+	}
+	assert.Nil(t, json.Unmarshal([]byte(readFile(t, p.path)), &banner))
+	assert.Equal(t, "INFO", banner.Level)
+	assert.Equal(t, "file opened", banner.Msg)
+	assert.Equal(t, "v1.2.3", banner.Data.Version)
+	assert.Equal(t, os.Getpid(), banner.Data.Pid)
+	assert.Equal(t, p.path, banner.Data.Filename)
+
+	for _, opts := range []string{`{"banner":"{{.Unclosed"}`, `{"banner_format":"xml"}`} {
+		_, err = ParseFileOpts(opts)
+		assert.NotNil(t, err, opts)
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	var opts FileOpts
+	assert.Nil(t, logger.UnmarshalOpts("file_mode=0640&dir_mode=750", &opts))
+	assert.Equal(t, FileMode(0640), opts.FileMode)
+	assert.Equal(t, FileMode(0750), opts.DirMode)
+	assert.Nil(t, logger.UnmarshalOpts(`{"file_mode":"600"}`, &opts))
+	assert.Equal(t, FileMode(0600), opts.FileMode)
+	assert.NotNil(t, logger.UnmarshalOpts(`{"file_mode":"0800"}`, &opts))
+	b, err := json.Marshal(FileMode(0640))
+	assert.Nil(t, err)
+	assert.Equal(t, `"0640"`, string(b))
+
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions and owners are not supported on windows")
+	}
+	dir, err := ioutil.TempDir("", "log_file_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	owner := fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+	p := NewFile(`{"dir":"` + filepath.ToSlash(dir) + `/sub","filename":"app","nosymlink":true,"file_mode":"0600","dir_mode":"0700","owner":"` + owner + `"}`).(*File)
+	assert.Equal(t, Healthy, p.Health().State)
+	assert.Nil(t, p.Close())
+	fi, err := os.Stat(p.path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	fi, err = os.Stat(filepath.Join(dir, "sub"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+
+	_, err = ParseFileOpts(`{"owner":"no-such-user-of-log-test"}`)
+	assert.NotNil(t, err)
+}
+
+func TestFileShared(t *testing.T) {
+	if !flockSupported {
+		t.Skip("flock unsupported")
+	}
+	dir, err := ioutil.TempDir("", "log_file_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// files opened by different processes
+	opts := `{"dir":"` + filepath.ToSlash(dir) + `","filename":"app","shared":true,"banner":"none","maxsize":64,"sync":"always"}`
+	p1 := NewFile(opts).(*File)
+	defer p1.Close()
+	p2 := NewFile(opts).(*File)
+	defer p2.Close()
+	assert.Equal(t, p1.path, p2.path)
+	first := p1.path
+
+	line := []byte(strings.Repeat("x", 39) + "\n")
+	assert.Nil(t, p1.Write(logger.INFO, 0, line))
+	assert.Nil(t, p2.Write(logger.INFO, 0, line))
+	assert.Equal(t, strings.Repeat(string(line), 2), readFile(t, first))
+
+	// p1 rotates after the file is full, and p2 follows instead of rotating again
+	p1.checkShared(time.Now())
+	assert.Nil(t, p1.Write(logger.INFO, 0, line))
+	assert.NotEqual(t, first, p1.path)
+	assert.Equal(t, 1, p1.fileIndex)
+	assert.Nil(t, p2.Write(logger.INFO, 0, line))
+	assert.Equal(t, p1.path, p2.path)
+	assert.Equal(t, 1, p2.fileIndex)
+	assert.Equal(t, strings.Repeat(string(line), 4), readFile(t, first))
+	assert.Nil(t, p1.Write(logger.INFO, 0, line))
+	assert.Nil(t, p2.Write(logger.INFO, 0, line))
+	assert.Equal(t, strings.Repeat(string(line), 2), readFile(t, p1.path))
+
+	// the symlink points to the latest file
+	target, err := os.Readlink(filepath.Join(dir, "app.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Base(p1.path), target)
+
+	// p2 detects rotation of p1
+	p1.currentSize = p1.config.MaxSize
+	assert.Nil(t, p1.Write(logger.INFO, 0, line))
+	assert.Equal(t, 2, p1.fileIndex)
+	p2.checkShared(time.Now())
+	assert.Equal(t, p1.path, p2.path)
+}
+
+func TestMultiFileRoutes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_multifile_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	p, err := OpenMultiFile(MultiFileOpts{
+		RootDir:   dir,
+		Filename:  "app",
+		Suffix:    ".log",
+		NoSymlink: true,
+		Banner:    "none",
+		Routes: []Route{
+			{MinLevel: "warn", Dir: "all", Tee: true},
+			{Field: "audit=true", Dir: "audit"},
+			{Module: "db", MaxLevel: "info", Filename: "db"},
+		},
+	})
+	assert.Nil(t, err)
+	l := logger.NewSync(p)
+	l.SetLevel(logger.TRACE)
+	l.NoHeader()
+	log := func(level logger.Level, ctx logger.Context, msg string) {
+		l.(logger.ContextWith).LogContext(level, 0, ctx, msg)
+	}
+	log(logger.INFO, logger.Context{}, "info")
+	log(logger.ERROR, logger.Context{}, "error")
+	log(logger.INFO, logger.Context{Value: map[string]interface{}{"audit": true}}, "audit")
+	log(logger.WARN, logger.Context{Value: map[string]interface{}{"audit": true}}, "warn audit")
+	log(logger.INFO, logger.Context{Value: map[string]interface{}{"audit": false}}, "not audit")
+	log(logger.DEBUG, logger.Context{Module: "db/sql"}, "db")
+	log(logger.ERROR, logger.Context{Module: "db"}, "db error")
+	log(logger.INFO, logger.Context{Module: "dbx"}, "dbx")
+	l.Quit()
+	assert.Nil(t, p.Close())
+
+	read := func(sub string) []string {
+		matches, err := filepath.Glob(filepath.Join(dir, sub, "*.log"))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(matches), sub)
+		if len(matches) != 1 {
+			return nil
+		}
+		return strings.Split(strings.TrimSpace(readFile(t, matches[0])), "\n")
+	}
+	assert.Equal(t, []string{"info", "not audit", "[dbx] dbx"}, read("info"))
+	assert.Equal(t, []string{"error", "[db] db error"}, read("error"))
+	assert.Equal(t, []string{"error", "warn audit", "[db] db error"}, read("all"))
+	assert.Equal(t, []string{"audit", "warn audit"}, read("audit"))
+	matches, _ := filepath.Glob(filepath.Join(dir, "db.*.log"))
+	assert.Equal(t, 1, len(matches))
+	assert.Equal(t, "[db/sql] db\n", readFile(t, matches[0]))
+	_, err = os.Stat(filepath.Join(dir, "warn"))
+	assert.True(t, os.IsNotExist(err), "warn directory created while it's not written")
+
+	_, err = ParseMultiFileOpts(`{"routes":[{"min_level":"loud"}]}`)
+	assert.NotNil(t, err)
+}
+
+func TestMultiFileRouteToLevelFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_multifile_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	p, err := OpenMultiFile(MultiFileOpts{
+		RootDir:   dir,
+		Filename:  "app",
+		Suffix:    ".log",
+		NoSymlink: true,
+		Banner:    "none",
+		Routes:    []Route{{Module: "db", Dir: "error"}},
+	})
+	assert.Nil(t, err)
+	l := logger.NewSync(p)
+	l.SetLevel(logger.TRACE)
+	l.NoHeader()
+	l.(logger.ContextWith).LogContext(logger.INFO, 0, logger.Context{Module: "db"}, "db")
+	l.(logger.ContextWith).LogContext(logger.ERROR, 0, logger.Context{}, "error")
+	l.Quit()
+	assert.Equal(t, 1, len(p.opened()), "route to error/ opened another file")
+	assert.Nil(t, p.Close())
+
+	matches, err := filepath.Glob(filepath.Join(dir, "error", "*.log"))
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(matches)) {
+		assert.Equal(t, "[db] db\nerror\n", readFile(t, matches[0]))
+	}
+}
@@ -0,0 +1,481 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkideal/log/logger"
+)
+
+var errInvalidFilter = errors.New("invalid filter")
+
+// Filter is a compiled filter expression which matches entries, e.g.
+//
+//	level >= warn && level <= error
+//	module ^= "db" || msg =~ "timeout|refused"
+//	!(field.user == "alice") && field.latency > 100
+//	field.audit
+//
+// Operands are level, module, msg and field.<name>, operators are == != < <= > >=
+// =~(regexp) !~(not regexp) and ^=(prefix), a field without operator matches if it's
+// present and not false, 0, "" or null. Levels are compared by severity, so
+// "level >= warn" matches WARN, ERROR, FATAL and PANIC. Fields are compared as numbers
+// if both sides are numbers. Values are quoted strings or bare words. Fields are their
+// JSON values except durations, which are compared as nanoseconds.
+//
+// Entries written by Write instead of WriteEntry have no module and fields.
+type Filter struct {
+	expr string
+	root filterNode
+}
+
+// ParseFilter compiles the filter expression
+func ParseFilter(expr string) (*Filter, error) {
+	p := &filterParser{src: expr}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return &Filter{expr: expr, root: root}, nil
+}
+
+// MustParseFilter similars to ParseFilter, but panic if parse failed
+func MustParseFilter(expr string) *Filter {
+	f, err := ParseFilter(expr)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// String returns the filter expression
+func (f *Filter) String() string { return f.expr }
+
+// Match reports whether the entry matches the filter
+func (f *Filter) Match(e logger.Entry) bool {
+	return f.root.eval(&filterInput{level: e.Level(), entry: e, msg: e.Desc()})
+}
+
+func (f *Filter) matchData(level logger.Level, headerLength int, data []byte) bool {
+	return f.root.eval(&filterInput{level: level, msg: bytes.TrimRight(data[headerLength:], "\n")})
+}
+
+// FilterProvider writes entries matching the filter to the inner provider
+type FilterProvider struct {
+	provider logger.Provider
+	filter   *Filter
+}
+
+// NewFilter creates a provider which writes entries matching f to p
+func NewFilter(p logger.Provider, f *Filter) logger.Provider {
+	return &FilterProvider{provider: p, filter: f}
+}
+
+func (p *FilterProvider) Write(level logger.Level, headerLength int, data []byte) error {
+	if p.filter.matchData(level, headerLength, data) {
+		return p.provider.Write(level, headerLength, data)
+	}
+	return nil
+}
+
+func (p *FilterProvider) WriteEntry(e logger.Entry) error {
+	if p.filter.Match(e) {
+		return logger.WriteEntry(p.provider, e)
+	}
+	return nil
+}
+
+func (p *FilterProvider) WriteBatch(entries []logger.Entry) error {
+	filtered := make([]logger.Entry, 0, len(entries))
+	for _, e := range entries {
+		if p.filter.Match(e) {
+			filtered = append(filtered, e)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+	return logger.WriteBatch(p.provider, filtered)
+}
+
+func (p *FilterProvider) Reopen() error { return logger.Reopen(p.provider) }
+
+func (p *FilterProvider) Close() error { return p.provider.Close() }
+
+// filterInput is the entry being matched, fields are parsed lazily
+type filterInput struct {
+	level  logger.Level
+	entry  logger.Entry // nil if written by Write
+	msg    []byte
+	fields map[string]interface{}
+	parsed bool
+}
+
+func (in *filterInput) field(name string) (interface{}, bool) {
+	if in.entry == nil {
+		return nil, false
+	}
+	if !in.parsed {
+		in.fields = entryFields(in.entry)
+		in.parsed = true
+	}
+	v, ok := in.fields[name]
+	return v, ok
+}
+
+type filterNode interface {
+	eval(in *filterInput) bool
+}
+
+type (
+	andNode struct{ x, y filterNode }
+	orNode  struct{ x, y filterNode }
+	notNode struct{ x filterNode }
+)
+
+func (n andNode) eval(in *filterInput) bool { return n.x.eval(in) && n.y.eval(in) }
+func (n orNode) eval(in *filterInput) bool  { return n.x.eval(in) || n.y.eval(in) }
+func (n notNode) eval(in *filterInput) bool { return !n.x.eval(in) }
+
+// levelNode compares level of entries with level by severity
+type levelNode struct {
+	op    string
+	level logger.Level
+}
+
+func (n levelNode) eval(in *filterInput) bool {
+	// less value is more severe
+	return compareInts(n.op, int(n.level), int(in.level))
+}
+
+// stringNode compares module or message with value
+type stringNode struct {
+	operand string // module or msg
+	op      string
+	value   string
+	re      *regexp.Regexp
+}
+
+func (n stringNode) eval(in *filterInput) bool {
+	var s string
+	if n.operand == "module" {
+		if in.entry != nil {
+			s = logger.Detailed(in.entry).Module()
+		}
+	} else {
+		s = string(in.msg)
+	}
+	return compareStrings(n.op, s, n.value, n.re)
+}
+
+// fieldNode compares a field with value, or checks whether the field is truthy if op is empty
+type fieldNode struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (n fieldNode) eval(in *filterInput) bool {
+	v, ok := in.field(n.name)
+	if n.op == "" {
+		return ok && truthy(v)
+	}
+	if !ok {
+		return n.op == "!=" || n.op == "!~"
+	}
+	s := fieldString(v)
+	switch n.op {
+	case "<", "<=", ">", ">=":
+		x, err1 := strconv.ParseFloat(s, 64)
+		y, err2 := strconv.ParseFloat(n.value, 64)
+		if err1 == nil && err2 == nil {
+			return compareFloats(n.op, x, y)
+		}
+	}
+	return compareStrings(n.op, s, n.value, n.re)
+}
+
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case float64:
+		return x != 0
+	case time.Duration:
+		return x != 0
+	}
+	return true
+}
+
+func compareInts(op string, x, y int) bool {
+	switch op {
+	case "==":
+		return x == y
+	case "!=":
+		return x != y
+	case "<":
+		return x < y
+	case "<=":
+		return x <= y
+	case ">":
+		return x > y
+	default:
+		return x >= y
+	}
+}
+
+func compareFloats(op string, x, y float64) bool {
+	switch op {
+	case "<":
+		return x < y
+	case "<=":
+		return x <= y
+	case ">":
+		return x > y
+	default:
+		return x >= y
+	}
+}
+
+func compareStrings(op, s, value string, re *regexp.Regexp) bool {
+	switch op {
+	case "==":
+		return s == value
+	case "!=":
+		return s != value
+	case "=~":
+		return re.MatchString(s)
+	case "!~":
+		return !re.MatchString(s)
+	case "^=":
+		return strings.HasPrefix(s, value)
+	case "<":
+		return s < value
+	case "<=":
+		return s <= value
+	case ">":
+		return s > value
+	default:
+		return s >= value
+	}
+}
+
+// filter tokens
+const (
+	tokWord   = iota // bare word
+	tokString        // quoted string
+	tokOp            // operator or parenthesis
+)
+
+type filterToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+type filterParser struct {
+	src    string
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%v %q: %s", errInvalidFilter, p.src, fmt.Sprintf(format, args...))
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '.' || c == '/' || c == '-' || c == ':' || c == '+'
+}
+
+func (p *filterParser) tokenize() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '`':
+			j := i + 1
+			for ; j < len(s) && s[j] != c; j++ {
+				if s[j] == '\\' && c == '"' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return p.errorf("unterminated string at %d", i)
+			}
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return p.errorf("invalid string at %d", i)
+			}
+			p.tokens = append(p.tokens, filterToken{kind: tokString, text: text, pos: i})
+			i = j + 1
+		case isWordChar(c):
+			j := i
+			for j < len(s) && isWordChar(s[j]) {
+				j++
+			}
+			p.tokens = append(p.tokens, filterToken{kind: tokWord, text: s[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			if i+1 < len(s) {
+				switch s[i : i+2] {
+				case "&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "^=":
+					op = s[i : i+2]
+				}
+			}
+			if op == "" {
+				switch c {
+				case '(', ')', '!', '<', '>':
+					op = s[i : i+1]
+				default:
+					return p.errorf("unexpected %q at %d", c, i)
+				}
+			}
+			p.tokens = append(p.tokens, filterToken{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+func (p *filterParser) peek(op string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokOp && p.tokens[p.pos].text == op
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("||") {
+		p.pos++
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = orNode{x, y}
+	}
+	return x, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("&&") {
+		p.pos++
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = andNode{x, y}
+	}
+	return x, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.peek("!") {
+		p.pos++
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	}
+	if p.peek("(") {
+		p.pos++
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+		return x, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, p.errorf("unexpected end")
+	}
+	tok := p.tokens[p.pos]
+	if tok.kind != tokWord {
+		return nil, p.errorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+	p.pos++
+	operand := tok.text
+
+	// field without operator
+	const fieldPrefix = "field."
+	isField := strings.HasPrefix(operand, fieldPrefix) && len(operand) > len(fieldPrefix)
+	op := ""
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokOp {
+		switch op = p.tokens[p.pos].text; op {
+		case "==", "!=", "<", "<=", ">", ">=", "=~", "!~", "^=":
+			p.pos++
+		default:
+			op = ""
+		}
+	}
+	if op == "" {
+		if isField {
+			return fieldNode{name: operand[len(fieldPrefix):]}, nil
+		}
+		return nil, p.errorf("operator required after %s", operand)
+	}
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind == tokOp {
+		return nil, p.errorf("value required after %s %s", operand, op)
+	}
+	value := p.tokens[p.pos].text
+	p.pos++
+
+	var (
+		re  *regexp.Regexp
+		err error
+	)
+	if op == "=~" || op == "!~" {
+		if re, err = regexp.Compile(value); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+	}
+	switch {
+	case operand == "level":
+		level, ok := logger.ParseLevel(value)
+		if !ok {
+			return nil, p.errorf("%v: %s", logger.ErrUnrecognizedLogLevel, value)
+		}
+		switch op {
+		case "=~", "!~", "^=":
+			return nil, p.errorf("operator %s unsupported for level", op)
+		}
+		return levelNode{op: op, level: level}, nil
+	case operand == "module", operand == "msg":
+		return stringNode{operand: operand, op: op, value: value, re: re}, nil
+	case isField:
+		return fieldNode{name: operand[len(fieldPrefix):], op: op, value: value, re: re}, nil
+	}
+	return nil, p.errorf("unknown operand %s", operand)
+}
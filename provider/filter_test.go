@@ -0,0 +1,139 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+)
+
+// capture records bodies of entries
+type capture struct {
+	bytes.Buffer
+}
+
+func (p *capture) Write(level logger.Level, headerLength int, data []byte) error {
+	p.Buffer.Write(data[headerLength:])
+	return nil
+}
+
+func (p *capture) Close() error { return nil }
+
+func logEntries(p logger.Provider) {
+	l := logger.NewSync(p)
+	l.SetLevel(logger.TRACE)
+	l.NoHeader()
+	log := func(level logger.Level, module string, fields map[string]interface{}, msg string) {
+		l.(logger.ContextWith).LogContext(level, 0, logger.Context{Module: module, Value: fields}, msg)
+	}
+	log(logger.DEBUG, "", nil, "debug")
+	log(logger.WARN, "db/sql", nil, "slow query")
+	log(logger.ERROR, "db", nil, "connection refused")
+	log(logger.INFO, "http", map[string]interface{}{"user": "alice", "latency": 120}, "request")
+	log(logger.INFO, "http", map[string]interface{}{"user": "bob", "latency": 80}, "request")
+	log(logger.INFO, "", map[string]interface{}{"audit": true}, "login")
+	l.Quit()
+}
+
+func TestFilter(t *testing.T) {
+	for _, tt := range []struct {
+		expr   string
+		output string
+	}{
+		{`level >= warn`, "[db/sql] slow query\n[db] connection refused\n"},
+		{`level < info`, "debug\n"},
+		{`level == error || level == debug`, "debug\n[db] connection refused\n"},
+		{`module ^= db && msg =~ "refused|timeout"`, "[db] connection refused\n"},
+		{`module == http && field.latency > 100`, "[http] request\n"},
+		{`field.user != "alice" && module == "http"`, "[http] request\n"},
+		{`!(level >= warn) && !field.user && !field.audit`, "debug\n"},
+		{`field.audit`, "login\n"},
+		{`msg !~ "^(debug|request)$" && (level == info || module == "db")`, "[db] connection refused\nlogin\n"},
+	} {
+		f, err := ParseFilter(tt.expr)
+		if !assert.Nil(t, err, tt.expr) {
+			continue
+		}
+		p := new(capture)
+		logEntries(NewFilter(p, f))
+		assert.Equal(t, tt.output, p.String(), tt.expr)
+	}
+
+	// written by Write
+	p := new(capture)
+	NewFilter(p, MustParseFilter(`level >= warn && msg == "hello"`)).Write(logger.WARN, 0, []byte("hello\n"))
+	assert.Equal(t, "hello\n", p.String())
+
+	for _, expr := range []string{
+		``, `level`, `level >`, `level >= loud`, `level =~ warn`, `(level > info`, `msg =~ "("`,
+		`size > 1`, `msg == "unterminated`, `level > info &&`, `level > info info`,
+	} {
+		_, err := ParseFilter(expr)
+		assert.NotNil(t, err, expr)
+	}
+}
+
+func TestFilterFieldTypes(t *testing.T) {
+	type request struct {
+		Latency time.Duration `json:"latency"`
+	}
+	// fields are compared in the same JSON representation whatever the context value is
+	f := MustParseFilter(`field.latency > 100`)
+	for _, value := range []interface{}{
+		map[string]interface{}{"latency": time.Duration(150)},
+		map[string]time.Duration{"latency": 150},
+		map[string]interface{}{"latency": logger.Duration(150)},
+		request{Latency: 150},
+		&request{Latency: 150},
+	} {
+		p := new(capture)
+		l := logger.NewSync(NewFilter(p, f))
+		l.SetLevel(logger.TRACE)
+		l.NoHeader()
+		l.(logger.ContextWith).LogContext(logger.INFO, 0, logger.Context{Value: value}, "request")
+		l.Quit()
+		assert.Equal(t, "request\n", p.String(), "%T", value)
+	}
+}
+
+type slowProvider struct {
+	capture
+	delay time.Duration
+	err   error
+}
+
+func (p *slowProvider) Write(level logger.Level, headerLength int, data []byte) error {
+	time.Sleep(p.delay)
+	if p.err != nil {
+		return p.err
+	}
+	return p.capture.Write(level, headerLength, data)
+}
+
+func TestIsolatedMix(t *testing.T) {
+	var (
+		fast    = new(capture)
+		slow    = &slowProvider{delay: 50 * time.Millisecond}
+		failing = &slowProvider{err: errors.New("unavailable")}
+		p       = NewIsolatedMixProvider(2, fast, slow, failing)
+	)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, p.Write(logger.INFO, 0, []byte("hello\n")))
+	}
+	assert.True(t, time.Since(start) < 50*time.Millisecond, "writing is delayed by slow child")
+	assert.Nil(t, p.Reopen())
+	stats := p.Stats()
+	assert.Equal(t, uint64(5), stats[0].Written+stats[0].Dropped)
+	assert.True(t, stats[1].Dropped > 0)
+	assert.Equal(t, uint64(5), stats[1].Written+stats[1].Dropped)
+	assert.True(t, stats[2].Errors > 0)
+	assert.Equal(t, uint64(5), stats[2].Errors+stats[2].Dropped)
+	assert.Equal(t, failing.err, stats[2].LastError)
+	assert.Nil(t, p.Close())
+	assert.Equal(t, int(stats[0].Written), bytes.Count(fast.Bytes(), []byte("hello\n")))
+}
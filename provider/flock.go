@@ -0,0 +1,24 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package provider
+
+import (
+	"os"
+	"syscall"
+)
+
+const flockSupported = true
+
+func flock(f *os.File) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+func funlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package provider
+
+import "os"
+
+const flockSupported = false
+
+func flock(f *os.File) error { return errFlockUnsupported }
+
+func funlock(f *os.File) error { return errFlockUnsupported }
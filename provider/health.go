@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mkideal/log/logger"
+)
+
+// HealthState represents state of a provider
+type HealthState int32
+
+const (
+	Healthy  HealthState = iota // works well
+	Degraded                    // works, but some operations failed, e.g. creating symlink
+	Failed                      // doesn't work, entries are written to the fallback provider if any
+)
+
+func (s HealthState) String() string {
+	switch s {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Failed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// Health represents health of a provider
+type Health struct {
+	State     HealthState
+	LastError error     // last error, nil if no error occurred
+	Since     time.Time // time of state changed
+}
+
+// HealthReporter is a provider which reports its health
+type HealthReporter interface {
+	Health() Health
+}
+
+// health records health of a provider, it's safe for concurrent use
+type health struct {
+	mu sync.Mutex
+	h  Health
+}
+
+func (h *health) get() Health {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.h
+}
+
+// set sets state and last error, last error is kept if err is nil
+func (h *health) set(state HealthState, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.h.State != state || h.h.Since.IsZero() {
+		h.h.Since = time.Now()
+	}
+	h.h.State = state
+	if err != nil {
+		h.h.LastError = err
+	}
+}
+
+// worse returns the worse one of h1 and h2
+func worse(h1, h2 Health) Health {
+	if h2.State > h1.State {
+		return h2
+	}
+	return h1
+}
+
+// newFallback creates fallback provider by name: stderr, stdout or empty
+func newFallback(name string) (logger.Provider, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "stderr":
+		return &Console{config: ConsoleOpts{ToStderrLevel: logger.TRACE}, stdout: os.Stdout, stderr: os.Stderr}, nil
+	case "stdout":
+		return &Console{config: ConsoleOpts{ToStderrLevel: logger.PANIC - 1}, stdout: os.Stdout, stderr: os.Stderr}, nil
+	}
+	return nil, fmt.Errorf("unsupported fallback %q", name)
+}
+
+// failedProvider is created by provider creators if options are invalid,
+// it writes entries to stderr and reports the error by Health
+type failedProvider struct {
+	health   Health
+	fallback logger.Provider
+}
+
+func newFailedProvider(err error) logger.Provider {
+	fallback, _ := newFallback("stderr")
+	return &failedProvider{
+		health:   Health{State: Failed, LastError: err, Since: time.Now()},
+		fallback: fallback,
+	}
+}
+
+func (p *failedProvider) Write(level logger.Level, headerLength int, data []byte) error {
+	p.fallback.Write(level, headerLength, data)
+	return p.health.LastError
+}
+
+func (p *failedProvider) Close() error   { return nil }
+func (p *failedProvider) Health() Health { return p.health }
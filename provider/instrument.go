@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"time"
+
+	"github.com/mkideal/log/logger"
+	"github.com/mkideal/log/metrics"
+)
+
+// Instrumented counts entries, bytes, errors and latency of writing of the inner
+// provider in metrics.Default with label provider=name
+type Instrumented struct {
+	provider logger.Provider
+	name     string
+	entries  [logger.NumLevel + 1]*metrics.Metric // indexed by level+1
+	bytes    *metrics.Metric
+	errors   *metrics.Metric
+	latency  *metrics.Metric
+}
+
+// Instrument creates an Instrumented provider which writes entries to p
+func Instrument(p logger.Provider, name string) *Instrumented {
+	ip := &Instrumented{
+		provider: p,
+		name:     name,
+		bytes:    metrics.WrittenBytes.With(name),
+		errors:   metrics.WriteErrors.With(name),
+		latency:  metrics.WriteLatency.With(name),
+	}
+	for i := range ip.entries {
+		ip.entries[i] = metrics.Entries.With(logger.Level(i-1).String(), name)
+	}
+	return ip
+}
+
+func (p *Instrumented) count(level logger.Level, data []byte) {
+	if i := int(level) + 1; i >= 0 && i < len(p.entries) {
+		p.entries[i].Inc()
+	}
+	p.bytes.Add(float64(len(data)))
+}
+
+func (p *Instrumented) done(start time.Time, err error) error {
+	p.latency.Observe(time.Since(start).Seconds())
+	if err != nil {
+		p.errors.Inc()
+	}
+	return err
+}
+
+// Write writes data to the inner provider
+func (p *Instrumented) Write(level logger.Level, headerLength int, data []byte) error {
+	p.count(level, data)
+	return p.done(time.Now(), p.provider.Write(level, headerLength, data))
+}
+
+// WriteEntry writes the entry to the inner provider
+func (p *Instrumented) WriteEntry(e logger.Entry) error {
+	p.count(e.Level(), logger.Detailed(e).Bytes())
+	return p.done(time.Now(), logger.WriteEntry(p.provider, e))
+}
+
+// WriteBatch writes entries to the inner provider, latency of the batch is observed once
+func (p *Instrumented) WriteBatch(entries []logger.Entry) error {
+	for _, e := range entries {
+		p.count(e.Level(), logger.Detailed(e).Bytes())
+	}
+	return p.done(time.Now(), logger.WriteBatch(p.provider, entries))
+}
+
+// Reopen reopens the inner provider
+func (p *Instrumented) Reopen() error {
+	return logger.Reopen(p.provider)
+}
+
+// Health implements HealthReporter interface, it reports health of the inner provider
+func (p *Instrumented) Health() Health {
+	if hr, ok := p.provider.(HealthReporter); ok {
+		return hr.Health()
+	}
+	return Health{}
+}
+
+// Close closes the inner provider
+func (p *Instrumented) Close() error {
+	return p.provider.Close()
+}
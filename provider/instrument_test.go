@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+	"github.com/mkideal/log/metrics"
+)
+
+func TestInstrument(t *testing.T) {
+	var (
+		ok      = new(capture)
+		failing = &slowProvider{err: errors.New("unavailable")}
+		values  = []*metrics.Metric{
+			metrics.Entries.With("INFO", "test_ok"),
+			metrics.Entries.With("ERROR", "test_ok"),
+			metrics.WrittenBytes.With("test_ok"),
+			metrics.WriteErrors.With("test_ok"),
+			metrics.WriteLatency.With("test_ok"),
+			metrics.WriteErrors.With("test_failing"),
+		}
+		old = make([]float64, len(values))
+	)
+	// metrics are global, so increments are checked
+	for i, m := range values {
+		old[i] = m.Value()
+	}
+	logEntries(Instrument(ok, "test_ok"))
+	logEntries(Instrument(failing, "test_failing"))
+	for i, want := range []float64{3, 1, float64(ok.Len()), 0, 6, 6} {
+		assert.Equal(t, want, values[i].Value()-old[i], "%dth", i)
+	}
+
+	var sb strings.Builder
+	metrics.Default.WriteTo(&sb)
+	assert.Contains(t, sb.String(), `log_entries_total{level="WARN",provider="test_ok"} `)
+	assert.Contains(t, sb.String(), `log_write_duration_seconds_bucket{provider="test_ok",le="+Inf"} `)
+}
+
+func TestAsyncProviderMetrics(t *testing.T) {
+	var (
+		inner   = &blockingProvider{unblock: make(chan struct{})}
+		p       = NewAsync(inner, AsyncOpts{QueueSize: 1, Name: "test_async"})
+		dropped = metrics.Dropped.With(metrics.DropQueueFull).Value()
+		depth   = metrics.QueueDepth.With("test_async")
+	)
+	p.Write(logger.INFO, 0, []byte("0\n"))
+	// waits until the worker blocked by the first entry
+	for p.Stats().Queued > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	p.Write(logger.INFO, 0, []byte("1\n"))
+	p.Write(logger.INFO, 0, []byte("2\n"))
+	// the first entry is being written, the second is queued and the last is dropped
+	assert.Equal(t, float64(1), depth.Value())
+	assert.Equal(t, dropped+1, metrics.Dropped.With(metrics.DropQueueFull).Value())
+	close(inner.unblock)
+	p.Close()
+
+	// the queue is unregistered while closed
+	var sb strings.Builder
+	metrics.Default.WriteTo(&sb)
+	assert.NotContains(t, sb.String(), `queue="test_async"`)
+}
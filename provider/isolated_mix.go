@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"github.com/mkideal/log/logger"
+)
+
+// ChildStats represents counters of a child of IsolatedMix
+type ChildStats struct {
+	Written   uint64 // number of entries written successfully
+	Dropped   uint64 // number of entries dropped while the queue is full
+	Errors    uint64 // number of entries failed to write
+	Queued    int    // number of entries in the queue
+	LastError error  // the last error returned by the child, nil if no error
+}
+
+// IsolatedMix writes entries to every child in its own goroutine with its own queue,
+// so a slow or failing child doesn't delay others. Entries are dropped if the queue
+// of a child is full, and errors of children are counted instead of returned.
+type IsolatedMix struct {
+	children []*AsyncProvider
+}
+
+// NewIsolatedMixProvider creates an IsolatedMix, queueSize is the capacity of the queue
+// of every child(default: 1024)
+func NewIsolatedMixProvider(queueSize int, first logger.Provider, others ...logger.Provider) *IsolatedMix {
+	p := new(IsolatedMix)
+	for _, provider := range append([]logger.Provider{first}, others...) {
+		p.children = append(p.children, NewAsync(provider, AsyncOpts{QueueSize: queueSize}))
+	}
+	return p
+}
+
+// Write queues data to all children
+func (p *IsolatedMix) Write(level logger.Level, headerLength int, data []byte) error {
+	var errs errorList
+	for _, c := range p.children {
+		errs.tryPush(c.Write(level, headerLength, data))
+	}
+	return errs.err()
+}
+
+// WriteEntry queues the entry to all children
+func (p *IsolatedMix) WriteEntry(e logger.Entry) error {
+	var errs errorList
+	for _, c := range p.children {
+		errs.tryPush(c.WriteEntry(e))
+	}
+	return errs.err()
+}
+
+// Reopen reopens all children after queued entries written
+func (p *IsolatedMix) Reopen() error {
+	var errs errorList
+	for _, c := range p.children {
+		errs.tryPush(c.Reopen())
+	}
+	return errs.err()
+}
+
+// Health implements HealthReporter interface, it reports the worst health of children
+func (p *IsolatedMix) Health() Health {
+	var h Health
+	for _, c := range p.children {
+		h = worse(h, c.Health())
+	}
+	return h
+}
+
+// Stats returns counters of children in order of creation
+func (p *IsolatedMix) Stats() []ChildStats {
+	stats := make([]ChildStats, len(p.children))
+	for i, c := range p.children {
+		s := c.Stats()
+		stats[i] = ChildStats{
+			Written:   s.Written,
+			Dropped:   s.Dropped,
+			Errors:    s.Errors,
+			Queued:    s.Queued,
+			LastError: s.LastError,
+		}
+	}
+	return stats
+}
+
+// Close writes queued entries and closes all children
+func (p *IsolatedMix) Close() error {
+	var errs errorList
+	for _, c := range p.children {
+		errs.tryPush(c.Close())
+	}
+	return errs.err()
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mkideal/log/logger"
+)
+
+// JSON formats entries as JSON lines and writes them to the inner provider, e.g.
+//
+//	{"time":"2020-04-01T10:00:00.123+08:00","level":"INFO","caller":"main.go:10","module":"db","msg":"hello","data":{"k":"v"}}
+type JSON struct {
+	provider logger.Provider
+}
+
+// NewJSON creates a JSON provider which writes JSON lines to p
+func NewJSON(p logger.Provider) logger.Provider {
+	return &JSON{provider: p}
+}
+
+type jsonEntry struct {
+	Time   string          `json:"time,omitempty"`
+	Level  logger.Level    `json:"level"`
+	Caller string          `json:"caller,omitempty"`
+	Module string          `json:"module,omitempty"`
+	Msg    string          `json:"msg"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Stack  logger.Frames   `json:"stack,omitempty"`
+}
+
+// Write writes formatted log as a JSON line, only level and message are available
+func (p *JSON) Write(level logger.Level, headerLength int, data []byte) error {
+	return p.write(level, &jsonEntry{
+		Level: level,
+		Msg:   string(bytes.TrimRight(data[headerLength:], "\n")),
+	})
+}
+
+// WriteEntry implements logger.EntryWriter interface
+func (p *JSON) WriteEntry(e logger.Entry) error {
+	d := logger.Detailed(e)
+	je := &jsonEntry{
+		Level:  d.Level(),
+		Module: d.Module(),
+		Msg:    string(d.Desc()),
+		Data:   jsonData(d),
+		Stack:  d.Stack(),
+	}
+	if t := d.Time(); !t.IsZero() {
+		je.Time = t.Format(time.RFC3339Nano)
+	}
+	if file, line := d.Caller(); file != "" {
+		je.Caller = file + ":" + strconv.Itoa(line)
+	}
+	return p.write(e.Level(), je)
+}
+
+func (p *JSON) write(level logger.Level, je *jsonEntry) error {
+	b, err := json.Marshal(je)
+	if err != nil {
+		return err
+	}
+	return p.provider.Write(level, 0, append(b, '\n'))
+}
+
+// jsonData returns the context data of e as JSON, the snapshot of the context value
+// taken while logging is preferred to the formatted body
+func jsonData(e logger.DetailedEntry) json.RawMessage {
+	if v := e.Value(); v != nil {
+		if b, err := json.Marshal(v); err == nil {
+			return b
+		}
+		b, _ := json.Marshal(fmt.Sprintf("%v", v))
+		return b
+	}
+	if body := e.Body(); len(body) > 0 {
+		if json.Valid(body) {
+			return append(json.RawMessage(nil), body...)
+		}
+		b, _ := json.Marshal(string(body))
+		return b
+	}
+	return nil
+}
+
+// Reopen reopens the inner provider
+func (p *JSON) Reopen() error { return logger.Reopen(p.provider) }
+
+// Close closes the inner provider
+func (p *JSON) Close() error { return p.provider.Close() }
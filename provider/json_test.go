@@ -0,0 +1,32 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+)
+
+func TestJSONContextModified(t *testing.T) {
+	buf := new(capture)
+	l := logger.New(NewJSON(buf))
+	l.SetLevel(logger.TRACE)
+	l.Run()
+
+	// the caller modifies the map while entries are written by the logger goroutine
+	m := map[string]interface{}{}
+	for i := 0; i < 100; i++ {
+		m["i"] = i
+		l.(logger.ContextWith).LogContext(logger.INFO, 0, logger.Context{Value: m}, "hello")
+	}
+	l.Quit()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 100, len(lines))
+	for i, line := range lines {
+		assert.True(t, strings.HasSuffix(line, fmt.Sprintf(`"msg":"hello","data":{"i":%d}}`, i)), line)
+	}
+}
@@ -10,6 +10,14 @@ func IsLevel(level logger.Level) LevelFilterFunc {
 	return func(lv logger.Level) bool { return level == lv }
 }
 
+// LevelRange returns a filter which accepts levels from min to max, min is the
+// least severe level and max is the most severe level, e.g.
+//
+//	LevelRange(logger.INFO, logger.WARN) // accepts INFO and WARN
+func LevelRange(min, max logger.Level) LevelFilterFunc {
+	return func(lv logger.Level) bool { return !lv.MoreVerboseThan(min) && !max.MoreVerboseThan(lv) }
+}
+
 type LevelFilter struct {
 	provider logger.Provider
 	filter   LevelFilterFunc
@@ -29,4 +37,26 @@ func (p *LevelFilter) Write(level logger.Level, headerLength int, data []byte) e
 	return nil
 }
 
+func (p *LevelFilter) WriteEntry(e logger.Entry) error {
+	if p.filter(e.Level()) {
+		return logger.WriteEntry(p.provider, e)
+	}
+	return nil
+}
+
+func (p *LevelFilter) WriteBatch(entries []logger.Entry) error {
+	filtered := make([]logger.Entry, 0, len(entries))
+	for _, e := range entries {
+		if p.filter(e.Level()) {
+			filtered = append(filtered, e)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+	return logger.WriteBatch(p.provider, filtered)
+}
+
+func (p *LevelFilter) Reopen() error { return logger.Reopen(p.provider) }
+
 func (p *LevelFilter) Close() error { return p.provider.Close() }
@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkideal/log/logger"
+	"github.com/mkideal/log/metrics"
+)
+
+// MetricRule represents a rule which derives a metric from entries, e.g. in JSON:
+//
+//	{"name": "app_errors_total", "filter": "level == error", "labels": ["module"]}
+//	{"name": "app_request_seconds", "type": "histogram", "filter": "msg == request_done", "field": "duration"}
+type MetricRule struct {
+	Name    string    `json:"name"`    // name of the metric
+	Help    string    `json:"help"`    // help of the metric(default: derived from filter)
+	Type    string    `json:"type"`    // counter, gauge or histogram(default: counter)
+	Filter  string    `json:"filter"`  // filter expression of entries, all entries matched if empty, see Filter
+	Labels  []string  `json:"labels"`  // sources of labels: level, module, msg or field.<name>
+	Field   string    `json:"field"`   // field added to counter, set to gauge or observed by histogram, counters count entries if empty
+	Buckets []float64 `json:"buckets"` // buckets of histogram(default: metrics.DefBuckets)
+}
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+func (rule *MetricRule) validate() error {
+	if !metricNameRegexp.MatchString(rule.Name) {
+		return fmt.Errorf("invalid metric name %q", rule.Name)
+	}
+	switch rule.Type {
+	case "", metrics.TypeCounter:
+	case metrics.TypeGauge, metrics.TypeHistogram:
+		if rule.Field == "" {
+			return fmt.Errorf("field of %s %s required", rule.Type, rule.Name)
+		}
+	default:
+		return fmt.Errorf("unsupported metric type %q", rule.Type)
+	}
+	for _, source := range rule.Labels {
+		if _, ok := labelName(source); !ok {
+			return fmt.Errorf("invalid label %q", source)
+		}
+	}
+	if rule.Filter != "" {
+		if _, err := ParseFilter(rule.Filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// labelName returns name of the label whose value is from source
+func labelName(source string) (string, bool) {
+	switch source {
+	case "level", "module", "msg":
+		return source, true
+	}
+	if !strings.HasPrefix(source, "field.") {
+		return "", false
+	}
+	name := strings.TrimPrefix(source, "field.")
+	return name, labelNameRegexp.MatchString(name)
+}
+
+// metricRule is a compiled MetricRule
+type metricRule struct {
+	filter *Filter
+	labels []string
+	field  string
+	vec    *metrics.Vec
+}
+
+// LogMetrics is a logger.Handler which derives metrics from entries by rules, e.g.
+// counts ERROR entries by module, or observes durations of requests by a histogram.
+// Metrics are registered to the registry and exposed in Prometheus text format
+// together with metrics of logging.
+//
+// Labels from messages and fields should have a few values, since every distinct
+// value creates a series.
+type LogMetrics struct {
+	rules []*metricRule
+}
+
+// NewLogMetrics creates a LogMetrics which registers metrics of rules to r,
+// metrics.Default is used if r is nil. Hook it to the logger, e.g.
+//
+//	h, err := provider.NewLogMetrics(nil, provider.MetricRule{
+//		Name:   "app_errors_total",
+//		Filter: "level == error",
+//		Labels: []string{"module"},
+//	})
+//	l.Hook(h)
+func NewLogMetrics(r *metrics.Registry, rules ...MetricRule) (*LogMetrics, error) {
+	if r == nil {
+		r = metrics.Default
+	}
+	h := new(LogMetrics)
+	names := make(map[string]bool)
+	for i := range rules {
+		rule := rules[i]
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate metric name %q", rule.Name)
+		}
+		names[rule.Name] = true
+		mr := &metricRule{labels: rule.Labels, field: rule.Field}
+		if rule.Filter != "" {
+			mr.filter = MustParseFilter(rule.Filter)
+		}
+		labels := make([]string, len(rule.Labels))
+		for j, source := range rule.Labels {
+			labels[j], _ = labelName(source)
+		}
+		help := rule.Help
+		if help == "" {
+			help = "Derived from entries"
+			if rule.Filter != "" {
+				help += " matching " + rule.Filter
+			}
+			help += "."
+		}
+		typ := rule.Type
+		if typ == "" {
+			typ = metrics.TypeCounter
+		}
+		var err error
+		if mr.vec, err = r.Register(typ, rule.Name, help, rule.Buckets, labels...); err != nil {
+			return nil, err
+		}
+		h.rules = append(h.rules, mr)
+	}
+	return h, nil
+}
+
+// Handle implements logger.Handler interface, an error is returned if value of
+// the field of a rule isn't a number or duration
+func (h *LogMetrics) Handle(e logger.Entry) error {
+	var (
+		fields  map[string]interface{}
+		fetched bool
+		errs    errorList
+	)
+	field := func(name string) (interface{}, bool) {
+		if !fetched {
+			fields, fetched = entryFields(e), true
+		}
+		v, ok := fields[name]
+		return v, ok
+	}
+	for _, rule := range h.rules {
+		if rule.filter != nil && !rule.filter.Match(e) {
+			continue
+		}
+		value := 1.0
+		if rule.field != "" {
+			v, ok := field(rule.field)
+			if !ok {
+				continue
+			}
+			var err error
+			if value, err = metricValue(v); err != nil {
+				errs.tryPush(fmt.Errorf("field %s: %v", rule.field, err))
+				continue
+			}
+		}
+		values := make([]string, len(rule.labels))
+		for i, source := range rule.labels {
+			switch source {
+			case "level":
+				values[i] = e.Level().String()
+			case "module":
+				values[i] = logger.Detailed(e).Module()
+			case "msg":
+				values[i] = string(e.Desc())
+			default:
+				v, _ := field(strings.TrimPrefix(source, "field."))
+				values[i] = fieldString(v)
+			}
+		}
+		m := rule.vec.With(values...)
+		switch rule.vec.Type() {
+		case metrics.TypeGauge:
+			m.Set(value)
+		case metrics.TypeHistogram:
+			m.Observe(value)
+		default:
+			m.Add(value)
+		}
+	}
+	return errs.err()
+}
+
+// metricValue converts value of a field to a number, durations and strings like "1.5s"
+// are converted to seconds
+func metricValue(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case time.Duration:
+		return x.Seconds(), nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	s := fieldString(v)
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d.Seconds(), nil
+	}
+	return 0, fmt.Errorf("%q is neither a number nor a duration", s)
+}
@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+	"github.com/mkideal/log/metrics"
+)
+
+func TestLogMetrics(t *testing.T) {
+	r := metrics.NewRegistry()
+	h, err := NewLogMetrics(r,
+		MetricRule{Name: "app_errors_total", Help: "Errors.", Filter: "level == error", Labels: []string{"module"}},
+		MetricRule{Name: "app_request_seconds", Help: "Requests.", Type: "histogram", Filter: "msg == request_done", Labels: []string{"field.method"}, Field: "duration", Buckets: []float64{0.1, 1}},
+		MetricRule{Name: "app_queue_size", Type: "gauge", Field: "queue"},
+	)
+	assert.Nil(t, err)
+
+	l := logger.NewSync(new(capture))
+	l.SetLevel(logger.TRACE)
+	l.Hook(h)
+	log := func(level logger.Level, module string, fields map[string]interface{}, msg string) {
+		l.(logger.ContextWith).LogContext(level, 0, logger.Context{Module: module, Value: fields}, msg)
+	}
+	log(logger.ERROR, "db", nil, "connection refused")
+	log(logger.ERROR, "db", nil, "timeout")
+	log(logger.ERROR, "http", nil, "bad gateway")
+	log(logger.WARN, "db", nil, "slow query")
+	log(logger.INFO, "", map[string]interface{}{"method": "GET", "duration": 50 * time.Millisecond}, "request_done")
+	log(logger.INFO, "", map[string]interface{}{"method": "GET", "duration": "500ms"}, "request_done")
+	log(logger.INFO, "", map[string]interface{}{"method": "POST", "duration": 2.5}, "request_done")
+	log(logger.INFO, "", map[string]interface{}{"method": "GET"}, "request_done")
+	log(logger.INFO, "", map[string]interface{}{"queue": 3}, "enqueued")
+	log(logger.INFO, "", map[string]interface{}{"queue": 1}, "dequeued")
+	l.Quit()
+
+	var sb strings.Builder
+	r.WriteTo(&sb)
+	assert.Equal(t, `# HELP app_errors_total Errors.
+# TYPE app_errors_total counter
+app_errors_total{module="db"} 2
+app_errors_total{module="http"} 1
+# HELP app_queue_size Derived from entries.
+# TYPE app_queue_size gauge
+app_queue_size 1
+# HELP app_request_seconds Requests.
+# TYPE app_request_seconds histogram
+app_request_seconds_bucket{method="GET",le="0.1"} 1
+app_request_seconds_bucket{method="GET",le="1"} 2
+app_request_seconds_bucket{method="GET",le="+Inf"} 2
+app_request_seconds_sum{method="GET"} 0.55
+app_request_seconds_count{method="GET"} 2
+app_request_seconds_bucket{method="POST",le="0.1"} 0
+app_request_seconds_bucket{method="POST",le="1"} 0
+app_request_seconds_bucket{method="POST",le="+Inf"} 1
+app_request_seconds_sum{method="POST"} 2.5
+app_request_seconds_count{method="POST"} 1
+`, sb.String())
+
+	rec := new(entryRecorder)
+	l = logger.NewSync(rec)
+	l.SetLevel(logger.INFO)
+	l.(logger.ContextWith).LogContext(logger.INFO, 0, logger.Context{Value: map[string]interface{}{"x": "fast"}}, "")
+	h, _ = NewLogMetrics(r, MetricRule{Name: "app_bad_total", Field: "x"})
+	assert.NotNil(t, h.Handle(rec.entries[0]))
+
+	for _, rule := range []MetricRule{
+		{Name: "bad-name"},
+		{Name: "x", Type: "summary"},
+		{Name: "x", Type: "histogram"},
+		{Name: "x", Labels: []string{"user"}},
+		{Name: "x", Labels: []string{"field.a-b"}},
+		{Name: "x", Filter: "level =="},
+		{Name: "app_errors_total", Type: "gauge", Field: "x"},
+		{Name: "app_errors_total", Labels: []string{"level"}},
+	} {
+		_, err := NewLogMetrics(r, rule)
+		assert.NotNil(t, err, "%+v", rule)
+	}
+	_, err = NewLogMetrics(r, MetricRule{Name: "app_dup_total"}, MetricRule{Name: "app_dup_total", Filter: "level == warn"})
+	assert.NotNil(t, err)
+}
+
+func TestMetricValue(t *testing.T) {
+	for _, tt := range []struct {
+		value interface{}
+		want  float64
+	}{
+		{1.5, 1.5},
+		{3, 3},
+		{"42", 42},
+		{"1m", 60},
+		{time.Second, 1},
+		{true, 1},
+	} {
+		got, err := metricValue(tt.value)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.want, got, "%v", tt.value)
+	}
+	_, err := metricValue("fast")
+	assert.NotNil(t, err)
+}
@@ -28,6 +28,33 @@ func (p *mixProvider) Write(level logger.Level, headerLength int, data []byte) e
 	return err.err()
 }
 
+// WriteEntry writes entry to all inner providers
+func (p *mixProvider) WriteEntry(e logger.Entry) error {
+	var err errorList
+	for _, op := range p.providers {
+		err.tryPush(logger.WriteEntry(op, e))
+	}
+	return err.err()
+}
+
+// WriteBatch writes entries to all inner providers
+func (p *mixProvider) WriteBatch(entries []logger.Entry) error {
+	var err errorList
+	for _, op := range p.providers {
+		err.tryPush(logger.WriteBatch(op, entries))
+	}
+	return err.err()
+}
+
+// Reopen reopens all inner providers
+func (p *mixProvider) Reopen() error {
+	var err errorList
+	for _, op := range p.providers {
+		err.tryPush(logger.Reopen(op))
+	}
+	return err.err()
+}
+
 // Close close all inner providers
 func (p *mixProvider) Close() error {
 	var err errorList
@@ -2,6 +2,8 @@ package provider
 
 import (
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/mkideal/log/logger"
 )
@@ -23,6 +25,34 @@ type MultiFileOpts struct {
 	DailyAppend bool   `json:"daily_append"` // append to existed file instead of creating a new file(default: true)
 	Suffix      string `json:"suffix"`       // filename suffix
 	DateFormat  string `json:"date_format"`  // date format string(default: %04d%02d%02d)
+	Watch       bool   `json:"watch"`        // reopens files if they're deleted or replaced(default: false)
+	Rotate      string `json:"rotate"`       // rotation schedule, see FileOpts.Rotate(default: daily)
+	Pattern     string `json:"pattern"`      // filename pattern, see FileOpts.Pattern
+	TimeZone    string `json:"timezone"`     // time zone of rotation boundaries and filenames(default: Local)
+
+	RetryMin  logger.Duration `json:"retry_min"`  // min interval of retrying to open files after failure(default: 1s)
+	RetryMax  logger.Duration `json:"retry_max"`  // max interval of retrying to open files(default: 1m)
+	Fallback  string          `json:"fallback"`   // fallback output used while files can't be opened: stderr, stdout or empty(default: )
+	DiskGuard *DiskGuardOpts  `json:"disk_guard"` // drops entries while free space of the volume is low, files on the same volume share a guard, nil disables the guard(default: nil)
+
+	BufferSize    int             `json:"buffer_size"`    // size of write buffer of every file(default: 16K)
+	FlushInterval logger.Duration `json:"flush_interval"` // interval of flushing buffers(default: 1s)
+	Sync          string          `json:"sync"`           // fsync policy, see FileOpts.Sync(default: interval)
+	FlushOnError  bool            `json:"flush_on_error"` // flushes buffer immediately after ERROR, FATAL and PANIC entries written(default: false)
+
+	FileMode     FileMode `json:"file_mode"`     // permission of created files before umask(default: 0666)
+	DirMode      FileMode `json:"dir_mode"`      // permission of created directories before umask(default: 0755)
+	Owner        string   `json:"owner"`         // chowns created files and directories, see FileOpts.Owner(default: )
+	Banner       string   `json:"banner"`        // template of banner, see FileOpts.Banner
+	BannerFormat string   `json:"banner_format"` // banner format: text or json(default: text)
+	AppVersion   string   `json:"app_version"`   // app version available in banner as {{.Version}}
+
+	Shared bool `json:"shared"` // coordinates rotation with other processes writing the same files, see FileOpts.Shared(default: false)
+
+	// Routes route entries to any directory and filename by level range, module or context field,
+	// they're matched in order until an entry written to a route without tee, unmatched entries
+	// are written to level directories.
+	Routes []Route `json:"routes"`
 }
 
 func NewMultiFileOpts() MultiFileOpts {
@@ -34,6 +64,26 @@ func NewMultiFileOpts() MultiFileOpts {
 	return opts
 }
 
+// ParseMultiFileOpts parses options of multifile provider from JSON or form string opts
+func ParseMultiFileOpts(opts string) (MultiFileOpts, error) {
+	config := NewMultiFileOpts()
+	if err := logger.UnmarshalOpts(opts, &config); err != nil {
+		return config, err
+	}
+	config.setDefaults()
+	return config, config.validate()
+}
+
+func (opts *MultiFileOpts) validate() error {
+	fileOpts := opts.fileOpts()
+	fileOpts.setDefaults()
+	if err := fileOpts.validate(); err != nil {
+		return err
+	}
+	_, err := opts.parseRoutes()
+	return err
+}
+
 func (opts *MultiFileOpts) setDefaults() {
 	if opts.RootDir == "" {
 		opts.RootDir = "."
@@ -59,12 +109,35 @@ func (opts *MultiFileOpts) setDefaults() {
 	if opts.DateFormat == "" {
 		opts.DateFormat = "%04d%02d%02d"
 	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 1 << 14 // 16k
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = logger.Duration(time.Second)
+	}
+	if opts.Sync == "" {
+		opts.Sync = SyncInterval
+	}
+	if opts.RetryMin <= 0 {
+		opts.RetryMin = logger.Duration(time.Second)
+	}
+	if opts.RetryMax < opts.RetryMin {
+		opts.RetryMax = logger.Duration(time.Minute)
+		if opts.RetryMax < opts.RetryMin {
+			opts.RetryMax = opts.RetryMin
+		}
+	}
 }
 
 type MultiFile struct {
 	config MultiFileOpts
-	files  [logger.NumLevel]*File
 	group  map[string][]logger.Level
+	routes []*route
+	guards *diskGuards // shared disk-space guards of files, nil if the guard disabled
+
+	mu    sync.Mutex
+	files [logger.NumLevel]*File // files of levels, created while first written
+	dests map[string]*File       // files of routes, created while first written
 }
 
 func abs(path string) string {
@@ -72,11 +145,33 @@ func abs(path string) string {
 	return s
 }
 
+// NewMultiFile creates multifile provider, it reports errors of opts by Health and
+// writes entries to stderr if opts are invalid. Use OpenMultiFile to get errors directly.
 func NewMultiFile(opts string) logger.Provider {
+	config, err := ParseMultiFileOpts(opts)
+	if err != nil {
+		return newFailedProvider(err)
+	}
+	return newMultiFile(config)
+}
+
+// OpenMultiFile creates multifile provider, files are opened while first written
+func OpenMultiFile(config MultiFileOpts) (*MultiFile, error) {
+	config.setDefaults()
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return newMultiFile(config), nil
+}
+
+func newMultiFile(config MultiFileOpts) *MultiFile {
 	p := new(MultiFile)
-	p.config = NewMultiFileOpts()
-	logger.UnmarshalOpts(opts, &p.config)
-	p.config.setDefaults()
+	p.config = config
+	p.routes, _ = config.parseRoutes()
+	p.dests = make(map[string]*File)
+	if config.DiskGuard != nil {
+		p.guards = newDiskGuards(*config.DiskGuard)
+	}
 	dirs := map[logger.Level]string{
 		logger.TRACE: abs(filepath.Join(p.config.RootDir, p.config.TraceDir)),
 		logger.DEBUG: abs(filepath.Join(p.config.RootDir, p.config.DebugDir)),
@@ -97,16 +192,127 @@ func NewMultiFile(opts string) logger.Provider {
 }
 
 func (p *MultiFile) Write(level logger.Level, headerLength int, data []byte) error {
+	return p.write(level, nil, headerLength, data)
+}
+
+// WriteEntry implements logger.EntryWriter interface, routes of module and field are
+// available only for entries
+func (p *MultiFile) WriteEntry(e logger.Entry) error {
+	d := logger.Detailed(e)
+	return p.write(d.Level(), e, d.HeaderLength(), d.Bytes())
+}
+
+func (p *MultiFile) write(level logger.Level, e logger.Entry, headerLength int, data []byte) error {
+	if len(p.routes) > 0 {
+		var (
+			errs   errorList
+			fields map[string]interface{}
+		)
+		for _, rt := range p.routes {
+			if !rt.match(level, e, &fields) {
+				continue
+			}
+			errs.tryPush(p.writeRoute(rt, level, headerLength, data))
+			if !rt.tee {
+				return errs.err()
+			}
+		}
+		errs.tryPush(p.writeLevel(level, headerLength, data))
+		return errs.err()
+	}
+	return p.writeLevel(level, headerLength, data)
+}
+
+// writeRoute writes data to the file of route rt, the file is created if not found.
+// The file of a level is used if the route has the same destination.
+func (p *MultiFile) writeRoute(rt *route, level logger.Level, headerLength int, data []byte) error {
+	if levels, ok := p.group[rt.dir]; ok && rt.filename == p.config.Filename {
+		f, err := p.levelFile(levels[0])
+		if err != nil {
+			return err
+		}
+		return f.Write(level, headerLength, data)
+	}
+	dest := rt.dest()
+	p.mu.Lock()
+	f, ok := p.dests[dest]
+	if !ok {
+		config := p.config.fileOpts()
+		config.Dir = rt.dir
+		config.Filename = rt.filename
+		f = newGuardedFile(config, p.guards)
+		p.dests[dest] = f
+	}
+	p.mu.Unlock()
+	return f.Write(level, headerLength, data)
+}
+
+// writeLevel writes data to the file of level
+func (p *MultiFile) writeLevel(level logger.Level, headerLength int, data []byte) error {
+	f, err := p.levelFile(level)
+	if err != nil {
+		return err
+	}
+	return f.Write(level, headerLength, data)
+}
+
+// levelFile returns the file of level, it's created if not found
+func (p *MultiFile) levelFile(level logger.Level) (*File, error) {
+	if level == logger.PANIC {
+		level = logger.FATAL
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if p.files[level] == nil {
 		if err := p.initForLevel(level); err != nil {
-			return err
+			return nil, err
+		}
+	}
+	return p.files[level], nil
+}
+
+// opened returns distinct files opened for levels and routes
+func (p *MultiFile) opened() []*File {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var (
+		files []*File
+		seen  = make(map[*File]bool)
+	)
+	for _, f := range p.files {
+		if f != nil && !seen[f] {
+			seen[f] = true
+			files = append(files, f)
 		}
 	}
-	return p.files[level].Write(level, headerLength, data)
+	for _, f := range p.dests {
+		files = append(files, f)
+	}
+	return files
+}
+
+// Health implements HealthReporter interface, it reports the worst health of opened files
+func (p *MultiFile) Health() Health {
+	var h Health
+	for _, f := range p.opened() {
+		h = worse(h, f.Health())
+	}
+	return h
+}
+
+// Reopen reopens all opened files
+func (p *MultiFile) Reopen() error {
+	var errs errorList
+	for _, f := range p.opened() {
+		errs.tryPush(f.Reopen())
+	}
+	return errs.err()
 }
 
 func (p *MultiFile) Close() error {
 	var errs errorList
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	for i := range p.files {
 		if i == 0 {
 			continue
@@ -116,14 +322,19 @@ func (p *MultiFile) Close() error {
 			p.files[i] = nil
 		}
 	}
+	for dest, f := range p.dests {
+		errs.tryPush(f.Close())
+		delete(p.dests, dest)
+	}
 	return errs.err()
 }
 
+// initForLevel creates the file of level, p.mu must be held
 func (p *MultiFile) initForLevel(level logger.Level) error {
 	if level < 0 || int(level) >= len(p.files) {
 		return errOutOfRange
 	}
-	f := newFile(p.configForLevel(level))
+	f := newGuardedFile(p.configForLevel(level), p.guards)
 	p.files[level] = f
 	if levels, ok := p.group[abs(f.config.Dir)]; ok {
 		for _, lv := range levels {
@@ -135,15 +346,42 @@ func (p *MultiFile) initForLevel(level logger.Level) error {
 	return nil
 }
 
-func (p *MultiFile) configForLevel(level logger.Level) FileOpts {
-	config := FileOpts{
-		MaxSize:     p.config.MaxSize,
-		NoSymlink:   p.config.NoSymlink,
-		Filename:    p.config.Filename,
-		DailyAppend: p.config.DailyAppend,
-		Suffix:      p.config.Suffix,
-		DateFormat:  p.config.DateFormat,
+// fileOpts returns options of files without directory
+func (opts *MultiFileOpts) fileOpts() FileOpts {
+	return FileOpts{
+		MaxSize:     opts.MaxSize,
+		NoSymlink:   opts.NoSymlink,
+		Filename:    opts.Filename,
+		DailyAppend: opts.DailyAppend,
+		Suffix:      opts.Suffix,
+		DateFormat:  opts.DateFormat,
+		Watch:       opts.Watch,
+		Rotate:      opts.Rotate,
+		Pattern:     opts.Pattern,
+		TimeZone:    opts.TimeZone,
+		RetryMin:    opts.RetryMin,
+		RetryMax:    opts.RetryMax,
+		Fallback:    opts.Fallback,
+		DiskGuard:   opts.DiskGuard,
+
+		BufferSize:    opts.BufferSize,
+		FlushInterval: opts.FlushInterval,
+		Sync:          opts.Sync,
+		FlushOnError:  opts.FlushOnError,
+
+		FileMode:     opts.FileMode,
+		DirMode:      opts.DirMode,
+		Owner:        opts.Owner,
+		Banner:       opts.Banner,
+		BannerFormat: opts.BannerFormat,
+		AppVersion:   opts.AppVersion,
+
+		Shared: opts.Shared,
 	}
+}
+
+func (p *MultiFile) configForLevel(level logger.Level) FileOpts {
+	config := p.config.fileOpts()
 	switch level {
 	case logger.FATAL, logger.ERROR:
 		config.Dir = filepath.Join(p.config.RootDir, p.config.ErrorDir)
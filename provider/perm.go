@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// FileMode is a permission mode used in options, it's encoded as an octal string like "0640"
+// in JSON, and a number in JSON is read as octal digits, e.g. 640 means 0640.
+type FileMode os.FileMode
+
+// MarshalJSON implements json.Marshaler
+func (m FileMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%04o", uint32(m)))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (m *FileMode) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var s string
+	switch x := v.(type) {
+	case float64:
+		s = strconv.FormatFloat(x, 'f', -1, 64)
+	case string:
+		s = x
+	default:
+		return fmt.Errorf("invalid file mode %s", data)
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return fmt.Errorf("invalid file mode %q", s)
+	}
+	*m = FileMode(mode)
+	return nil
+}
+
+// parseOwner parses owner in form "user", "user:group", "uid" or "uid:gid",
+// -1 is returned if user or group is unspecified
+func parseOwner(owner string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if owner == "" {
+		return
+	}
+	name, group := owner, ""
+	if i := strings.IndexByte(owner, ':'); i >= 0 {
+		name, group = owner[:i], owner[i+1:]
+	}
+	if name != "" {
+		if uid, err = strconv.Atoi(name); err != nil {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return -1, -1, err
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return -1, -1, fmt.Errorf("unsupported uid %q of user %s", u.Uid, name)
+			}
+		}
+	}
+	if group != "" {
+		if gid, err = strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, err
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return -1, -1, fmt.Errorf("unsupported gid %q of group %s", g.Gid, group)
+			}
+		}
+	}
+	return uid, gid, nil
+}
@@ -0,0 +1,245 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule computes rotation times
+type schedule interface {
+	// next returns the first rotation time after t
+	next(t time.Time) time.Time
+}
+
+// parseSchedule parses rotation schedule:
+//
+//	""/"daily"/"1d": every day at midnight
+//	"hourly"/"1h", "30m", "2h", "7d": every N minutes/hours/days, aligned to midnight
+//	"0 */6 * * *": cron-like schedule "minute hour day-of-month month day-of-week"
+//	"none": never rotates by time
+func parseSchedule(s string, loc *time.Location) (schedule, error) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "", "daily":
+		return periodSchedule{n: 1, unit: 'd', loc: loc}, nil
+	case "hourly":
+		return periodSchedule{n: 1, unit: 'h', loc: loc}, nil
+	case "none":
+		return noSchedule{}, nil
+	}
+	if strings.ContainsRune(s, ' ') {
+		return parseCron(s, loc)
+	}
+	unit := s[len(s)-1]
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 || (unit != 'm' && unit != 'h' && unit != 'd') {
+		return nil, fmt.Errorf("invalid rotation schedule %q", s)
+	}
+	return periodSchedule{n: n, unit: unit, loc: loc}, nil
+}
+
+type noSchedule struct{}
+
+func (noSchedule) next(t time.Time) time.Time {
+	return time.Unix(1<<62, 0)
+}
+
+// periodSchedule rotates every n minutes, hours or days. Minutes and hours are
+// aligned to midnight, days are aligned to the Unix epoch in the location.
+type periodSchedule struct {
+	n    int
+	unit byte // m, h or d
+	loc  *time.Location
+}
+
+func (s periodSchedule) next(t time.Time) time.Time {
+	t = t.In(s.loc)
+	y, m, d := t.Date()
+	if s.unit == 'd' {
+		days := int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+		return time.Date(y, m, d+s.n-days%s.n, 0, 0, 0, 0, s.loc)
+	}
+	period := time.Duration(s.n) * time.Minute
+	if s.unit == 'h' {
+		period *= 60
+	}
+	// computed in absolute time since the midnight, wall clock times are ambiguous
+	// while daylight saving time ends
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, s.loc)
+	tomorrow := time.Date(y, m, d+1, 0, 0, 0, 0, s.loc)
+	next := midnight.Add((t.Sub(midnight)/period + 1) * period)
+	if !next.Before(tomorrow) {
+		return tomorrow
+	}
+	return next
+}
+
+// cronSchedule rotates at times matching a cron-like spec
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bit sets
+	domStar, dowStar              bool
+	loc                           *time.Location
+}
+
+var errInvalidCron = errors.New("invalid cron spec")
+
+func parseCron(spec string, loc *time.Location) (schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%v %q: 5 fields required", errInvalidCron, spec)
+	}
+	var (
+		s   = &cronSchedule{loc: loc}
+		err error
+	)
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := [5]*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow}
+	for i, field := range fields {
+		if *sets[i], err = parseCronField(field, bounds[i][0], bounds[i][1]); err != nil {
+			return nil, fmt.Errorf("%v %q: %v", errInvalidCron, spec, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 is also Sunday
+	}
+	s.domStar = fields[2] == "*"
+	s.dowStar = fields[4] == "*"
+	return s, nil
+}
+
+// parseCronField parses comma-separated list of "*", "n", "a-b", "*/step" or "a-b/step"
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		var (
+			lo, hi = min, max
+			step   = 1
+			err    error
+		)
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			part = part[:i]
+		}
+		if part != "*" {
+			if i := strings.IndexByte(part, '-'); i >= 0 {
+				lo, err = strconv.Atoi(part[:i])
+				if err == nil {
+					hi, err = strconv.Atoi(part[i+1:])
+				}
+			} else {
+				lo, err = strconv.Atoi(part)
+				hi = lo
+				if step > 1 {
+					hi = max
+				}
+			}
+			if err != nil || lo < min || hi > max || lo > hi {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.In(s.loc)
+	y, m, d := t.Date()
+	hour, minute, _ := t.Clock()
+	t = later(t, time.Date(y, m, d, hour, minute+1, 0, 0, s.loc))
+	// searchs at most 5 years
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		y, m, d = t.Date()
+		hour, minute, _ = t.Clock()
+		switch {
+		case s.month&(1<<uint(m)) == 0:
+			t = later(t, time.Date(y, m+1, 1, 0, 0, 0, 0, s.loc))
+		case !s.matchDay(t):
+			t = later(t, time.Date(y, m, d+1, 0, 0, 0, 0, s.loc))
+		case s.hour&(1<<uint(hour)) == 0:
+			t = later(t, time.Date(y, m, d, hour+1, 0, 0, 0, s.loc))
+		case s.minute&(1<<uint(minute)) == 0:
+			t = later(t, time.Date(y, m, d, hour, minute+1, 0, 0, s.loc))
+		default:
+			return t
+		}
+	}
+	return noSchedule{}.next(t)
+}
+
+// later returns next if it's after t, otherwise the next minute of t. A wall clock
+// time may be earlier than t while daylight saving time ends.
+func later(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Truncate(time.Minute).Add(time.Minute)
+}
+
+// formatFilename formats t by pattern, pattern is a strftime pattern if it contains '%',
+// otherwise it's a Go layout, e.g. "app-%Y%m%d-%H.log" or "app-20060102-15.log"
+func formatFilename(pattern string, t time.Time) string {
+	if !strings.ContainsRune(pattern, '%') {
+		return t.Format(pattern)
+	}
+	return strftime(pattern, t)
+}
+
+// strftime supports %Y %y %m %d %H %M %S %j %b %a %s and %%
+func strftime(pattern string, t time.Time) string {
+	var buf strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' || i+1 == len(pattern) {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y':
+			fmt.Fprintf(&buf, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&buf, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&buf, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&buf, "%02d", t.Day())
+		case 'H':
+			fmt.Fprintf(&buf, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&buf, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&buf, "%02d", t.Second())
+		case 'j':
+			fmt.Fprintf(&buf, "%03d", t.YearDay())
+		case 'b':
+			buf.WriteString(t.Format("Jan"))
+		case 'a':
+			buf.WriteString(t.Format("Mon"))
+		case 's':
+			buf.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case '%':
+			buf.WriteByte('%')
+		default:
+			buf.WriteByte('%')
+			buf.WriteByte(pattern[i])
+		}
+	}
+	return buf.String()
+}
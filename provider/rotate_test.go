@@ -0,0 +1,162 @@
+package provider
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeriodSchedule(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	at := func(s string) time.Time {
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", s, loc)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	for _, tt := range []struct {
+		spec string
+		now  string
+		next string
+	}{
+		{"", "2020-04-01 10:37:00", "2020-04-02 00:00:00"},
+		{"hourly", "2020-04-01 10:37:00", "2020-04-01 11:00:00"},
+		{"hourly", "2020-04-01 23:00:00", "2020-04-02 00:00:00"},
+		{"30m", "2020-04-01 10:30:00", "2020-04-01 11:00:00"},
+		{"15m", "2020-04-01 10:37:59", "2020-04-01 10:45:00"},
+		{"2h", "2020-04-01 11:59:59", "2020-04-01 12:00:00"},
+		{"7m", "2020-04-01 23:56:00", "2020-04-02 00:00:00"},
+		{"2d", "2020-04-01 10:00:00", "2020-04-02 00:00:00"},
+		{"2d", "2020-04-02 10:00:00", "2020-04-04 00:00:00"},
+		{"0 */6 * * *", "2020-04-01 10:37:00", "2020-04-01 12:00:00"},
+		{"30 2 * * *", "2020-04-01 10:37:00", "2020-04-02 02:30:00"},
+		{"0 0 1 * *", "2020-04-01 00:00:00", "2020-05-01 00:00:00"},
+		{"0 0 * * 0", "2020-04-01 10:00:00", "2020-04-05 00:00:00"},
+		{"0 0 * * 7", "2020-04-01 10:00:00", "2020-04-05 00:00:00"},
+		{"0 0 15 * 1", "2020-04-01 10:00:00", "2020-04-06 00:00:00"},
+		{"0,30 9-17 * * 1-5", "2020-04-03 17:45:00", "2020-04-06 09:00:00"},
+		{"0 0 29 2 *", "2020-04-01 10:00:00", "2024-02-29 00:00:00"},
+	} {
+		s, err := parseSchedule(tt.spec, loc)
+		if !assert.Nil(t, err, tt.spec) {
+			continue
+		}
+		assert.Equal(t, at(tt.next), s.next(at(tt.now)), "%s at %s", tt.spec, tt.now)
+	}
+	for _, spec := range []string{"1w", "0h", "h", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		_, err := parseSchedule(spec, loc)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestScheduleDSTFallBack(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	// 2020-11-01 02:00 EDT falls back to 01:00 EST
+	edt := time.Date(2020, 11, 1, 5, 15, 0, 0, time.UTC) // 01:15 EDT
+	est := edt.Add(time.Hour)                            // 01:15 EST
+	for _, tt := range []struct {
+		spec string
+		now  time.Time
+		next time.Time
+	}{
+		{"30m", edt, edt.Add(15 * time.Minute)},
+		{"30m", est, est.Add(15 * time.Minute)},
+		{"hourly", edt, edt.Add(45 * time.Minute)},
+		{"hourly", est, est.Add(45 * time.Minute)},
+		{"30 * * * *", edt, edt.Add(15 * time.Minute)},
+		{"30 * * * *", est, est.Add(15 * time.Minute)},
+		{"10 * * * *", edt, edt.Add(115 * time.Minute)}, // 01:10 EST is skipped
+		{"10 * * * *", est, est.Add(55 * time.Minute)},
+	} {
+		s, err := parseSchedule(tt.spec, loc)
+		if !assert.Nil(t, err, tt.spec) {
+			continue
+		}
+		next := s.next(tt.now)
+		assert.True(t, next.Equal(tt.next), "%s at %v: %v", tt.spec, tt.now.In(loc), next)
+	}
+}
+
+func TestFormatFilename(t *testing.T) {
+	now := time.Date(2020, 4, 1, 9, 5, 7, 0, time.UTC)
+	assert.Equal(t, "app-20200401-09.log", formatFilename("app-%Y%m%d-%H.log", now))
+	assert.Equal(t, "app-200401-0905-07-092-Apr-Wed-%q-%.log", formatFilename("app-%y%m%d-%H%M-%S-%j-%b-%a-%q-%%.log", now))
+	assert.Equal(t, "app-2020-04-01T09.log", formatFilename("app-2006-01-02T15.log", now))
+}
+
+func TestFileRotateByPattern(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_rotate_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	p := NewFile(`{"dir":"` + filepath.ToSlash(dir) + `","pattern":"app-%Y%m%d-%H.txt","rotate":"hourly","timezone":"UTC","nosymlink":true}`).(*File)
+	defer p.Close()
+
+	now := time.Date(2020, 4, 1, 9, 5, 7, 0, time.UTC)
+	p.mu.Lock()
+	p.nextRotation = time.Time{}
+	assert.Nil(t, p.rotate(now))
+	assert.Equal(t, filepath.Join(dir, "app-20200401-09.txt"), p.path)
+	assert.Equal(t, time.Date(2020, 4, 1, 10, 0, 0, 0, time.UTC), p.nextRotation)
+
+	// rotated by size in the same period
+	assert.Nil(t, p.rotate(now.Add(time.Minute)))
+	assert.Equal(t, filepath.Join(dir, "app-20200401-09.001.txt"), p.path)
+
+	assert.Nil(t, p.rotate(now.Add(time.Hour)))
+	assert.Equal(t, filepath.Join(dir, "app-20200401-10.txt"), p.path)
+	p.mu.Unlock()
+}
+
+func TestFilePeriodNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_rotate_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	opts := func(s string) string {
+		return `{"dir":"` + filepath.ToSlash(dir) + `","timezone":"UTC","nosymlink":true,` + s + `}`
+	}
+
+	// patterns which can't tell periods apart are rejected, otherwise files of
+	// previous periods are truncated or appended
+	for _, s := range []string{
+		`"pattern":"app-%Y%m%d.log","rotate":"hourly","daily_append":false`,
+		`"pattern":"app-%Y%m%d.log","rotate":"30m"`,
+		`"pattern":"app-%Y%m.log","rotate":"daily"`,
+		`"pattern":"app-%Y%m%d-%H.log","rotate":"*/30 * * * *"`,
+		`"pattern":"app-%Y%m%d-%H.log","rotate":"30m"`,
+	} {
+		_, err := ParseFileOpts(opts(s))
+		assert.NotNil(t, err, s)
+	}
+	for _, s := range []string{
+		`"pattern":"app-%Y%m%d-%H.log","rotate":"2h"`,
+		`"pattern":"app-%Y%m%d.log","rotate":"7d"`,
+		`"pattern":"app.log","rotate":"none"`,
+		`"pattern":"app-%Y%m%d-%H.log","rotate":"0 * * * *"`,
+		`"rotate":"0 */6 * * *"`,
+		`"rotate":"hourly","daily_append":false`,
+	} {
+		_, err := ParseFileOpts(opts(s))
+		assert.Nil(t, err, s)
+	}
+
+	// hour and minute are appended to daily appended files if the date format can't tell periods apart
+	p := NewFile(opts(`"filename":"app","rotate":"30m"`)).(*File)
+	defer p.Close()
+	now := time.Date(2020, 4, 1, 9, 5, 7, 0, time.UTC)
+	p.mu.Lock()
+	p.nextRotation = time.Time{}
+	assert.Nil(t, p.rotate(now))
+	assert.Equal(t, filepath.Join(dir, "app.20200401-0905.log"), p.path)
+	assert.Nil(t, p.rotate(now.Add(30*time.Minute)))
+	assert.Equal(t, filepath.Join(dir, "app.20200401-0935.log"), p.path)
+	p.mu.Unlock()
+}
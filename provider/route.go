@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mkideal/log/logger"
+)
+
+// Route routes entries matching all of its conditions to a file of MultiFile, e.g.
+//
+//	{"min_level":"warn","dir":"all","tee":true} // WARN and above also go to all/
+//	{"field":"audit=true","dir":"audit"}      // entries with field audit=true go to audit/
+//	{"module":"db","filename":"db"}           // entries of module db and its submodules go to db.<date>.log
+type Route struct {
+	MinLevel string `json:"min_level"` // the least severe level(default: trace)
+	MaxLevel string `json:"max_level"` // the most severe level(default: panic)
+	Module   string `json:"module"`    // module name, submodules like "db/sql" and "db.sql" match "db" too
+	Field    string `json:"field"`     // "key" matches entries with the field, "key=value" matches the value
+	Dir      string `json:"dir"`       // subdirectory of RootDir, the file of a level is shared if it has the same path(default: .)
+	Filename string `json:"filename"`  // log filename(default: MultiFileOpts.Filename)
+	Tee      bool   `json:"tee"`       // continues matching next routes after the entry written
+}
+
+// route is a parsed Route
+type route struct {
+	min, max   logger.Level
+	module     string
+	key, value string
+	hasValue   bool
+	dir        string // absolute directory
+	filename   string
+	tee        bool
+}
+
+func parseRoute(r Route, rootDir, filename string) (*route, error) {
+	rt := &route{
+		min:      logger.TRACE,
+		max:      logger.PANIC,
+		module:   r.Module,
+		dir:      abs(filepath.Join(rootDir, r.Dir)),
+		filename: r.Filename,
+		tee:      r.Tee,
+	}
+	var ok bool
+	if r.MinLevel != "" {
+		if rt.min, ok = logger.ParseLevel(r.MinLevel); !ok {
+			return nil, fmt.Errorf("%v: %s", logger.ErrUnrecognizedLogLevel, r.MinLevel)
+		}
+	}
+	if r.MaxLevel != "" {
+		if rt.max, ok = logger.ParseLevel(r.MaxLevel); !ok {
+			return nil, fmt.Errorf("%v: %s", logger.ErrUnrecognizedLogLevel, r.MaxLevel)
+		}
+	}
+	if rt.filename == "" {
+		rt.filename = filename
+	}
+	rt.key = r.Field
+	if i := strings.IndexByte(r.Field, '='); i >= 0 {
+		rt.key, rt.value, rt.hasValue = r.Field[:i], r.Field[i+1:], true
+	}
+	return rt, nil
+}
+
+// parseRoutes parses routes of opts
+func (opts *MultiFileOpts) parseRoutes() ([]*route, error) {
+	routes := make([]*route, 0, len(opts.Routes))
+	for i, r := range opts.Routes {
+		rt, err := parseRoute(r, opts.RootDir, opts.Filename)
+		if err != nil {
+			return nil, fmt.Errorf("routes[%d]: %v", i, err)
+		}
+		routes = append(routes, rt)
+	}
+	return routes, nil
+}
+
+// dest returns the destination of the route
+func (rt *route) dest() string {
+	return rt.dir + string(filepath.Separator) + rt.filename
+}
+
+// match reports whether the entry matches the route, e is nil if the entry is written by Write
+func (rt *route) match(level logger.Level, e logger.Entry, fields *map[string]interface{}) bool {
+	if level.MoreVerboseThan(rt.min) || rt.max.MoreVerboseThan(level) {
+		return false
+	}
+	if rt.module != "" {
+		if e == nil || !matchModule(rt.module, logger.Detailed(e).Module()) {
+			return false
+		}
+	}
+	if rt.key != "" {
+		if e == nil {
+			return false
+		}
+		if *fields == nil {
+			*fields = entryFields(e)
+		}
+		v, ok := (*fields)[rt.key]
+		if !ok || (rt.hasValue && fieldString(v) != rt.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchModule reports whether module is name or a submodule of name
+func matchModule(name, module string) bool {
+	if !strings.HasPrefix(module, name) {
+		return false
+	}
+	return len(module) == len(name) || module[len(name)] == '/' || module[len(name)] == '.'
+}
@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errFlockUnsupported = errors.New("flock unsupported")
+
+// sharedState is the current file shared by processes, it's stored in the state file
+// as "<created unix nano> <index> <path>"
+type sharedState struct {
+	created time.Time
+	index   int
+	path    string
+}
+
+// sharedPath returns path of lock file or state file of shared mode
+func (p *File) sharedPath(ext string) string {
+	name := p.config.Filename
+	if name == "" {
+		name = filepath.Base(os.Args[0])
+	}
+	return filepath.Join(p.config.Dir, "."+name+ext)
+}
+
+// lock locks the lock file exclusively, it blocks until the lock acquired
+func (p *File) lock() error {
+	if p.lockFile == nil {
+		if err := os.MkdirAll(p.config.Dir, os.FileMode(p.config.DirMode)); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(p.sharedPath(".lock"), os.O_RDWR|os.O_CREATE, os.FileMode(p.config.FileMode))
+		if err != nil {
+			return err
+		}
+		p.lockFile = f
+	}
+	return flock(p.lockFile)
+}
+
+func (p *File) unlock() error {
+	return funlock(p.lockFile)
+}
+
+// readState reads the shared state, zero state is returned if it doesn't exist or is broken
+func (p *File) readState() sharedState {
+	var st sharedState
+	data, err := ioutil.ReadFile(p.sharedPath(".state"))
+	if err != nil {
+		return st
+	}
+	fields := strings.SplitN(strings.TrimSuffix(string(data), "\n"), " ", 3)
+	if len(fields) != 3 {
+		return st
+	}
+	created, err1 := strconv.ParseInt(fields[0], 10, 64)
+	index, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		return st
+	}
+	st.created = time.Unix(0, created).In(p.loc)
+	st.index = index
+	st.path = fields[2]
+	return st
+}
+
+// writeState writes current file as the shared state atomically
+func (p *File) writeState() error {
+	name := p.sharedPath(".state")
+	tmp := fmt.Sprintf("%s.%d", name, pid)
+	data := fmt.Sprintf("%d %d %s\n", p.createdTime.UnixNano(), p.fileIndex, p.path)
+	if err := ioutil.WriteFile(tmp, []byte(data), os.FileMode(p.config.FileMode)); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// rotateShared rotates the file while holding the lock file, so only one process creates
+// the next file, others follow the file recorded in the shared state.
+func (p *File) rotateShared(now time.Time) error {
+	if err := p.lock(); err != nil {
+		p.failed(now, err)
+		return err
+	}
+	defer p.unlock()
+
+	p.nextRotation = p.schedule.next(now)
+	st := p.readState()
+	p.fileIndex = 0
+	if st.path != "" && p.schedule.next(st.created).Equal(p.nextRotation) {
+		// current file was created in this period by some process
+		if fi, err := os.Stat(st.path); err == nil && int(fi.Size()) < p.config.MaxSize {
+			return p.follow(now, st)
+		}
+		p.fileIndex = (st.index + 1) % 1000
+	}
+	if err := p.openNext(now); err != nil {
+		return err
+	}
+	if err := p.writeState(); err != nil {
+		p.health.set(Degraded, err)
+	}
+	return nil
+}
+
+// follow switches to the file created by another process
+func (p *File) follow(now time.Time, st sharedState) error {
+	if st.path == p.path && p.writer != nil {
+		return nil
+	}
+	closeErr := p.closeCurrent()
+	file, err := os.OpenFile(st.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, os.FileMode(p.config.FileMode))
+	if err != nil {
+		p.failed(now, err)
+		return err
+	}
+	p.opened(file)
+	p.path = st.path
+	p.createdTime = st.created
+	p.fileIndex = st.index
+	if fi, err := file.Stat(); err == nil {
+		p.currentSize = int(fi.Size())
+	}
+	if closeErr != nil {
+		p.health.set(Degraded, closeErr)
+	}
+	return nil
+}
+
+// checkShared follows the file if another process rotated it, and refreshes the size
+// of current file which is written by all processes
+func (p *File) checkShared(now time.Time) {
+	if st := p.readState(); st.path != "" && st.path != p.path {
+		p.rotateShared(now)
+		return
+	}
+	if p.file != nil {
+		if fi, err := p.file.Stat(); err == nil {
+			p.currentSize = int(fi.Size())
+		}
+	}
+}
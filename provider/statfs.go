@@ -0,0 +1,29 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package provider
+
+import (
+	"strconv"
+	"syscall"
+)
+
+func statfs(dir string) (diskUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return diskUsage{}, err
+	}
+	return diskUsage{
+		freeBytes:  int64(st.Bavail) * int64(st.Bsize),
+		freeInodes: int64(st.Ffree),
+	}, nil
+}
+
+// volumeOf returns the id of the volume of dir
+func volumeOf(dir string) (string, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(dir, &st); err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(uint64(st.Dev), 10), nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package provider
+
+func statfs(dir string) (diskUsage, error) {
+	return diskUsage{}, errStatfsUnsupported
+}
+
+func volumeOf(dir string) (string, error) {
+	return "", errStatfsUnsupported
+}
@@ -0,0 +1,107 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mkideal/log/logger"
+)
+
+// Secret is a string which always renders masked, e.g.
+//
+//	log.With(log.M{"user": name, "password": log.Secret(password)}).Info("login")
+type Secret string
+
+// String implements fmt.Stringer
+func (s Secret) String() string { return logger.Mask }
+
+// Format implements fmt.Formatter
+func (s Secret) Format(f fmt.State, verb rune) { io.WriteString(f, logger.Mask) }
+
+// MarshalJSON implements json.Marshaler
+func (s Secret) MarshalJSON() ([]byte, error) { return []byte(`"` + logger.Mask + `"`), nil }
+
+// SensitiveFields is a list of commonly used names of sensitive fields
+var SensitiveFields = []string{
+	"password", "passwd", "pwd", "secret", "token", "access_token", "refresh_token",
+	"api_key", "apikey", "authorization", "cookie", "credit_card", "card_number",
+}
+
+// map[string]bool, keys are lower case field names
+var redactedFields atomic.Value
+
+func init() {
+	redactedFields.Store(map[string]bool(nil))
+}
+
+// RedactFields sets names of fields which are masked in context data, names are case-insensitive.
+// Fields of M and map[string]interface{}, including nested ones, are checked.
+func RedactFields(names ...string) {
+	if len(names) == 0 {
+		redactedFields.Store(map[string]bool(nil))
+		return
+	}
+	fields := make(map[string]bool, len(names))
+	for _, name := range names {
+		fields[strings.ToLower(name)] = true
+	}
+	redactedFields.Store(fields)
+}
+
+// SetRedactors sets redactors of global logger applied to context data and message
+func SetRedactors(redactors ...logger.Redactor) error {
+	r, ok := glogger().(logger.Redactable)
+	if !ok {
+		return ErrUnsupported
+	}
+	r.SetRedactors(redactors...)
+	return nil
+}
+
+// redactValue returns a copy of v whose sensitive fields are masked
+func redactValue(v interface{}) interface{} {
+	fields := redactedFields.Load().(map[string]bool)
+	if len(fields) == 0 {
+		return v
+	}
+	return redactFields(v, fields)
+}
+
+func redactFields(v interface{}, fields map[string]bool) interface{} {
+	switch x := v.(type) {
+	case M:
+		m := make(M, len(x))
+		for key, val := range x {
+			m[key] = redactField(key, val, fields)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for key, val := range x {
+			m[key] = redactField(key, val, fields)
+		}
+		return m
+	case S:
+		s := make(S, len(x))
+		for i, val := range x {
+			s[i] = redactFields(val, fields)
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(x))
+		for i, val := range x {
+			s[i] = redactFields(val, fields)
+		}
+		return s
+	}
+	return v
+}
+
+func redactField(key string, val interface{}, fields map[string]bool) interface{} {
+	if fields[strings.ToLower(key)] {
+		return Secret("")
+	}
+	return redactFields(val, fields)
+}
@@ -0,0 +1,37 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	s := Secret("123456")
+	assert.Equal(t, "******", fmt.Sprint(s))
+	assert.Equal(t, "******", fmt.Sprintf("%s", s))
+	assert.Equal(t, "******", fmt.Sprintf("%#v", s))
+	b, err := json.Marshal(M{"password": s})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"password":"******"}`, string(b))
+}
+
+func TestRedactFields(t *testing.T) {
+	w := new(bytes.Buffer)
+	RedactFields("Password", "token")
+	defer RedactFields()
+	for _, with := range [2]bool{true, false} {
+		initMockLogger(w, with)
+
+		data := M{"user": "a", "password": "123", "nested": M{"TOKEN": "x"}}
+		WithJSON(data).Info("login")
+		checkTestResult(t, w, `{"nested":{"TOKEN":"******"},"password":"******","user":"a"} | login`, "redact-json")
+		assert.Equal(t, "123", data["password"])
+
+		With(S{"a", M{"password": "123"}}).Info("")
+		checkTestResult(t, w, "[a map[password:******]]", "redact-text")
+	}
+}
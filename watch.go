@@ -0,0 +1,199 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mkideal/log/logger"
+)
+
+// ReloadConfig applies the configuration to global logger. If global logger supports
+// logger.ProviderSwapper, providers are swapped atomically without losing queued entries
+// and old providers are closed after draining, in that case cfg.Sync is ignored.
+// Otherwise, global logger is replaced by a new logger.
+func ReloadConfig(cfg *Config) error {
+	gloggerMu.Lock()
+	l := glogger()
+	swapper, ok := l.(logger.ProviderSwapper)
+	if !ok {
+		gloggerMu.Unlock()
+		return InitWithConfig(cfg)
+	}
+	defer gloggerMu.Unlock()
+	p, err := cfg.newProvider()
+	if err != nil {
+		return err
+	}
+	if err := swapper.SetProvider(p); err != nil {
+		glogger().Warn(1, "close old providers error: %v", err)
+	}
+	cfg.configure(l)
+	return nil
+}
+
+// WatchOpts represents options of ConfigWatcher
+type WatchOpts struct {
+	Interval time.Duration     // polling interval of config file(default: 5s), negative disables polling
+	NoSignal bool              // don't reload on SIGHUP
+	OnReload func(cfg *Config) // called after configuration reloaded
+	OnError  func(err error)   // called if reloading failed(default: logs the error by global logger)
+}
+
+// ConfigWatcher reloads configuration of global logger while the config file
+// changed or SIGHUP received
+type ConfigWatcher struct {
+	filename  string
+	envPrefix string
+	opts      WatchOpts
+
+	mu      sync.Mutex
+	content []byte // content of config file loaded last time
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// WatchConfig inits global logger by configuration loaded by LoadConfig(filename, envPrefix),
+// and then watches it. Call Close to stop watching.
+func WatchConfig(filename, envPrefix string, opts WatchOpts) (*ConfigWatcher, error) {
+	if opts.Interval == 0 {
+		opts.Interval = 5 * time.Second
+	}
+	w := &ConfigWatcher{
+		filename:  filename,
+		envPrefix: envPrefix,
+		opts:      opts,
+		quit:      make(chan struct{}),
+	}
+	content, cfg, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	if err := InitWithConfig(cfg); err != nil {
+		return nil, err
+	}
+	w.content = content
+	var sig chan os.Signal
+	if !opts.NoSignal {
+		sig = make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGHUP)
+	}
+	w.wg.Add(1)
+	go w.run(sig)
+	return w, nil
+}
+
+func (w *ConfigWatcher) load() ([]byte, *Config, error) {
+	var content []byte
+	if w.filename != "" {
+		var err error
+		if content, err = ioutil.ReadFile(w.filename); err != nil {
+			return nil, nil, &ConfigError{Err: err}
+		}
+	}
+	cfg, err := LoadConfig(w.filename, w.envPrefix)
+	return content, cfg, err
+}
+
+func (w *ConfigWatcher) run(sig chan os.Signal) {
+	defer w.wg.Done()
+	var tick <-chan time.Time
+	if w.opts.Interval > 0 && w.filename != "" {
+		ticker := time.NewTicker(w.opts.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	if sig != nil {
+		defer signal.Stop(sig)
+	}
+	for {
+		select {
+		case <-w.quit:
+			return
+		case <-sig:
+			w.reload(true)
+		case <-tick:
+			w.reload(false)
+		}
+	}
+}
+
+// Reload reloads configuration whether the config file changed or not
+func (w *ConfigWatcher) Reload() error {
+	return w.reload(true)
+}
+
+func (w *ConfigWatcher) reload(force bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !force {
+		content, err := ioutil.ReadFile(w.filename)
+		if err != nil || bytes.Equal(content, w.content) {
+			// keeps current configuration while the file is missing, e.g. being replaced
+			return nil
+		}
+	}
+	content, cfg, err := w.load()
+	if err == nil {
+		err = ReloadConfig(cfg)
+	}
+	if err != nil {
+		if w.opts.OnError != nil {
+			w.opts.OnError(err)
+		} else {
+			glogger().Error(1, "reload log config error: %v", err)
+		}
+		// don't retry the same content
+		w.content = content
+		return err
+	}
+	w.content = content
+	if w.opts.OnReload != nil {
+		w.opts.OnReload(cfg)
+	}
+	return nil
+}
+
+// Close stops watching
+func (w *ConfigWatcher) Close() error {
+	close(w.quit)
+	w.wg.Wait()
+	return nil
+}
+
+// ReopenOnSignal reopens files of global logger while any of signals received(default: SIGHUP),
+// it's useful for logrotate which moves files and then sends a signal. Call stop to stop it.
+func ReopenOnSignal(signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+	var (
+		sig  = make(chan os.Signal, 1)
+		quit = make(chan struct{})
+		done = make(chan struct{})
+	)
+	signal.Notify(sig, signals...)
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-quit:
+				return
+			case <-sig:
+				if err := Reopen(); err != nil {
+					glogger().Error(1, "reopen log files error: %v", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sig)
+		close(quit)
+		<-done
+	}
+}
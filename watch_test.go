@@ -0,0 +1,84 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mkideal/log/logger"
+)
+
+func TestWatchConfig(t *testing.T) {
+	defer InitWithLogger(logger.NewStdLogger())
+	dir, err := ioutil.TempDir("", "log_watch_test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "log.yaml")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("level: info\noutputs:\n  - type: config_test\n"), 0644))
+
+	reloaded := make(chan *Config, 1)
+	errors := make(chan error, 1)
+	w, err := WatchConfig(filename, "", WatchOpts{
+		Interval: 10 * time.Millisecond,
+		NoSignal: true,
+		OnReload: func(cfg *Config) { reloaded <- cfg },
+		OnError:  func(err error) { errors <- err },
+	})
+	assert.Nil(t, err)
+	defer w.Close()
+	assert.Equal(t, LvINFO, GetLevel())
+	l := glogger()
+
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("level: debug\nmodules:\n  db: trace\noutputs:\n  - type: config_test\n"), 0644))
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("config not reloaded")
+	}
+	assert.Equal(t, LvDEBUG, GetLevel())
+	assert.Equal(t, LvTRACE, glogger().(logger.ModuleLeveler).GetModuleLevel("db"))
+	assert.True(t, l == glogger(), "providers should be swapped")
+
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("level: verbose\n"), 0644))
+	select {
+	case err := <-errors:
+		_, ok := err.(*ConfigError)
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("error not reported")
+	}
+	assert.Equal(t, LvDEBUG, GetLevel())
+}
+
+func TestInitWithLoggerConcurrently(t *testing.T) {
+	defer InitWithLogger(logger.NewStdLogger())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				InitWithLogger(logger.New(newDiscardProvider()))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				With(M{"j": j}).Trace("concurrent")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+type discardProvider struct{}
+
+func newDiscardProvider() logger.Provider { return discardProvider{} }
+
+func (discardProvider) Write(level logger.Level, headerLength int, data []byte) error { return nil }
+func (discardProvider) Close() error                                                  { return nil }